@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/shanisharrma/gopher-social/internal/audit"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+type UpdateRolePayload struct {
+	Role string `json:"role" validate:"required,oneof=user moderator admin"`
+}
+
+// UpdateUserRole godoc
+//
+//	@Summary		Changes a user's role
+//	@Description	Changes a user's role. The change is recorded in the audit log.
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"User ID"
+//	@Param			payload	body		UpdateRolePayload	true	"Role payload"
+//	@Success		204		{string}	string				"Role updated"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{id}/role [put]
+func (app *application) updateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	target := app.targetUserFromParam(w, r)
+	if target == nil {
+		return
+	}
+
+	var payload UpdateRolePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.SetRole(ctx, target.ID, payload.Role); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.invalidateUser(ctx, target.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Role updated", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UnlockUser godoc
+//
+//	@Summary		Unlocks a user's account
+//	@Description	Lifts the lockout of a user's account after failed logins and forgets the failures. The unlock is recorded in the audit log.
+//	@Tags			Admin
+//	@Produce		json
+//	@Param			id	path		int		true	"User ID"
+//	@Success		204	{string}	string	"User unlocked"
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{id}/unlock [put]
+func (app *application) unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	target := app.targetUserFromParam(w, r)
+	if target == nil {
+		return
+	}
+
+	if err := app.store.LoginThrottles.Unlock(r.Context(), target); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "User unlocked", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetAuditLog godoc
+//
+//	@Summary		Queries the audit log
+//	@Description	Fetches privileged actions, most recent first
+//	@Tags			Admin
+//	@Produce		json
+//	@Param			actor_id	query		int		false	"Actor user ID"
+//	@Param			action		query		string	false	"Action, e.g. post.delete"
+//	@Param			target_type	query		string	false	"Target type"
+//	@Param			target_id	query		int		false	"Target ID"
+//	@Param			since		query		string	false	"Since (RFC3339)"
+//	@Param			until		query		string	false	"Until (RFC3339)"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			offset		query		int		false	"Offset"
+//	@Success		200			{object}	[]audit.Entry
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/audit [get]
+func (app *application) getAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	q := audit.Query{
+		Limit:  50,
+		Offset: 0,
+	}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	entries, err := app.store.AuditLog.List(r.Context(), q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Audit log fetched", entries); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -16,10 +16,17 @@ import (
 	"github.com/go-chi/cors"
 	"github.com/shanisharrma/gopher-social/cmd/api/config"
 	"github.com/shanisharrma/gopher-social/internal/auth"
+	"github.com/shanisharrma/gopher-social/internal/events"
+	"github.com/shanisharrma/gopher-social/internal/features"
+	"github.com/shanisharrma/gopher-social/internal/health"
 	"github.com/shanisharrma/gopher-social/internal/mailer"
+	"github.com/shanisharrma/gopher-social/internal/metrics"
+	"github.com/shanisharrma/gopher-social/internal/notifications"
 	"github.com/shanisharrma/gopher-social/internal/ratelimiter"
 	"github.com/shanisharrma/gopher-social/internal/store"
 	"github.com/shanisharrma/gopher-social/internal/store/cache"
+	"github.com/shanisharrma/gopher-social/internal/tracing"
+	"github.com/shanisharrma/gopher-social/internal/trending"
 	"go.uber.org/zap"
 
 	"github.com/shanisharrma/gopher-social/docs" // this is required for generating docs
@@ -33,78 +40,231 @@ type application struct {
 	logger        *zap.SugaredLogger
 	mailer        mailer.Client
 	authenticator auth.Authenticator
-	ratelimiter   ratelimiter.Limiter
+	ratelimiters  ratelimiter.Policies
+	notifications notifications.Service
+	events        events.Broker
+	presence      events.Presence
+	trending      trending.Results
+	health        *health.Checker
+	features      *features.Flags
+	jobs          *jobs
+
+	// shutdown is closed when the server starts shutting down, so long lived
+	// requests such as event streams return instead of holding it up.
+	shutdown chan struct{}
 }
 
 func (app *application) mount() http.Handler {
 	r := chi.NewRouter()
 
+	// the proxies were checked when the configuration was loaded, and none
+	// are trusted otherwise
+	proxies, _ := app.config.Proxies()
+
+	r.Use(tracing.Middleware)
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(realIPMiddleware(proxies))
+	r.Use(app.accessLogMiddleware)
+	r.Use(app.metricsMiddleware)
 	r.Use(middleware.Recoverer)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{app.config.FrontendURL},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "x-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
 		AllowCredentials: false,
 		MaxAge:           300, // maximum value not ignored by any major browsers
 	}))
 
+	// Probes and metrics are polled by the infrastructure, so they are kept
+	// out of the rate limiter
+	r.Get("/v1/health/live", app.livenessHandler)
+	r.Get("/v1/health/ready", app.readinessHandler)
+	r.With(app.BasicAuthMiddleware()).Get("/metrics", metrics.Handler().ServeHTTP)
+
+	limited := chi.Router(r)
 	if app.config.Ratelimiter.Enabled {
-		r.Use(app.RateLimiterMiddleware)
+		limited = r.With(app.RateLimiterMiddleware)
 	}
 
-	// Set a timeout value on the request context (ctx), that will signal
-	// through ctx.Done() that the request has timed out and further
-	// processing should be stopped.
-	r.Use(middleware.Timeout(60 * time.Second))
+	limited.Route("/v1", func(r chi.Router) {
+		// Live updates, long lived so they are not subject to the request timeout
+		r.With(app.AuthTokenMiddleware).Get("/stream", app.streamHandler)
+		r.With(app.AuthTokenMiddleware, app.postsContextMiddleware).
+			Get("/posts/{postID}/live", app.livePostHandler)
+
+		r.Group(func(r chi.Router) {
+			// Set a timeout value on the request context (ctx), that will signal
+			// through ctx.Done() that the request has timed out and further
+			// processing should be stopped.
+			r.Use(middleware.Timeout(60 * time.Second))
+
+			//Operations
+			r.Get("/health", app.healthCheckHandler)
+			r.With(app.BasicAuthMiddleware()).Get("/debug/vars", expvar.Handler().ServeHTTP)
+
+			docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.Addr)
+			r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
+
+			// Posts
+			r.Route("/posts", func(r chi.Router) {
+				r.With(app.AuthTokenMiddleware, app.rateLimit(config.PolicyCreatePost)).Post("/", app.createPostHandler)
+
+				r.Route("/{postID}", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Use(app.postsContextMiddleware)
+
+					r.Get("/", app.getPostHandler)
+					r.Patch("/", app.checkPostOwnership("moderator", true, app.updatePostHandler))
+					r.Delete("/", app.checkPostOwnership("admin", true, app.deletePostHandler))
+
+					r.Put("/pin", app.pinPostHandler)
+					r.Put("/unpin", app.unpinPostHandler)
+					r.Post("/poll/votes", app.votePollHandler)
+					r.Post("/comments", app.createCommentHandler)
+					r.Delete("/comments/{commentID}", app.deleteCommentHandler)
+				})
+			})
 
-	r.Route("/v1", func(r chi.Router) {
+			// Users
+			r.Route("/users", func(r chi.Router) {
+				r.Put("/activate/{token}", app.activateUserHandler)
+
+				r.Route("/{userID}", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+
+					r.Get("/", app.getUserHandler)
+					r.Put("/follow", app.followUserHandler)
+					r.Put("/unfollow", app.unfollowUserHandler)
+					r.Put("/block", app.blockUserHandler)
+					r.Put("/unblock", app.unblockUserHandler)
+					r.Put("/mute", app.muteUserHandler)
+					r.Put("/unmute", app.unmuteUserHandler)
+					r.Get("/posts", app.getUserPostsHandler)
+					r.Get("/communities", app.getUserCommunitiesHandler)
+				})
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+
+					r.Get("/feed", app.getUserFeedHandler)
+					r.Put("/privacy", app.updatePrivacyHandler)
+					r.Get("/blocks", app.getBlockedUsersHandler)
+					r.Get("/mutes", app.getMutedUsersHandler)
+
+					r.Route("/follow-requests", func(r chi.Router) {
+						r.Get("/", app.getFollowRequestsHandler)
+						r.Put("/{userID}/approve", app.approveFollowRequestHandler)
+						r.Put("/{userID}/reject", app.rejectFollowRequestHandler)
+					})
+				})
+			})
 
-		//Operations
-		r.Get("/health", app.healthCheckHandler)
-		r.With(app.BasicAuthMiddleware()).Get("/debug/vars", expvar.Handler().ServeHTTP)
+			// Discover
+			r.With(app.AuthTokenMiddleware).Get("/trending", app.getTrendingHandler)
 
-		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.Addr)
-		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
+			// Hashtags
+			r.Route("/tags/{tag}", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
 
-		// Posts
-		r.Route("/posts", func(r chi.Router) {
-			r.Post("/", app.createPostHandler)
+				r.Get("/posts", app.getTagPostsHandler)
+			})
 
-			r.Route("/{postID}", func(r chi.Router) {
+			// Communities
+			r.Route("/communities", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
-				r.Use(app.postsContextMiddleware)
 
-				r.Get("/", app.getPostHandler)
-				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
-				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
+				r.Post("/", app.createCommunityHandler)
+				r.Get("/invitations", app.getCommunityInvitationsHandler)
+
+				r.Route("/{communityID}", func(r chi.Router) {
+					r.Use(app.communityContextMiddleware)
+
+					r.Get("/", app.getCommunityHandler)
+					r.Put("/join", app.joinCommunityHandler)
+					r.Put("/leave", app.leaveCommunityHandler)
+					r.Post("/invitations", app.inviteToCommunityHandler)
+					r.Get("/members", app.getCommunityMembersHandler)
+					r.Put("/members/{userID}/role", app.setCommunityRoleHandler)
+					r.Delete("/members/{userID}", app.removeCommunityMemberHandler)
+					r.Get("/posts", app.getCommunityPostsHandler)
+				})
 			})
-		})
 
-		// Users
-		r.Route("/users", func(r chi.Router) {
-			r.Put("/activate/{token}", app.activateUserHandler)
+			// Direct messages
+			r.Route("/conversations", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+
+				r.Get("/", app.getConversationsHandler)
+				r.Post("/", app.createConversationHandler)
 
-			r.Route("/{userID}", func(r chi.Router) {
+				r.Route("/{conversationID}", func(r chi.Router) {
+					r.Use(app.conversationContextMiddleware)
+
+					r.Get("/", app.getConversationHandler)
+					r.Get("/messages", app.getMessagesHandler)
+					r.Post("/messages", app.sendMessageHandler)
+					r.Put("/read", app.markConversationReadHandler)
+				})
+			})
+
+			// Notifications
+			r.Route("/notifications", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
 
-				r.Get("/", app.getUserHandler)
-				r.Put("/follow", app.followUserHandler)
-				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Get("/", app.getNotificationsHandler)
+				r.Get("/unread-count", app.getUnreadNotificationsCountHandler)
+				r.Put("/read", app.markNotificationsReadHandler)
+				r.Put("/read-all", app.markAllNotificationsReadHandler)
+				r.Get("/preferences", app.getNotificationPreferencesHandler)
+				r.Put("/preferences", app.updateNotificationPreferenceHandler)
 			})
 
-			r.Group(func(r chi.Router) {
-				r.Get("/feed", app.getUserFeedHandler)
+			// Reports
+			r.Route("/reports", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+
+				r.Post("/", app.createReportHandler)
 			})
-		})
 
-		// public routes
-		r.Route("/authentication", func(r chi.Router) {
-			r.Post("/user", app.registerUserHandler)
-			r.Post("/token", app.createTokenHandler)
+			// Moderation
+			r.Route("/moderation", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Use(app.requireRole("moderator"))
+
+				r.Get("/reports", app.getModerationQueueHandler)
+				r.Route("/reports/{reportID}", func(r chi.Router) {
+					r.Get("/", app.getReportHandler)
+					r.Post("/actions", app.moderateReportHandler)
+				})
+			})
+
+			// Admin
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Use(app.requireRole("admin"))
+
+				r.Get("/audit", app.getAuditLogHandler)
+
+				r.Route("/features", func(r chi.Router) {
+					r.Get("/", app.getFeatureFlagsHandler)
+					r.Put("/{key}", app.setFeatureFlagHandler)
+				})
+
+				r.Route("/users/{userID}", func(r chi.Router) {
+					r.Put("/role", app.updateUserRoleHandler)
+					r.Put("/unlock", app.unlockUserHandler)
+					r.Get("/suspensions", app.getSuspensionsHandler)
+					r.Post("/suspensions", app.createSuspensionHandler)
+				})
+			})
+
+			// public routes
+			r.Route("/authentication", func(r chi.Router) {
+				r.With(app.requireFeature(features.Registration), app.rateLimit(config.PolicyRegister)).
+					Post("/user", app.registerUserHandler)
+				r.With(app.rateLimit(config.PolicyLogin)).Post("/token", app.createTokenHandler)
+			})
 		})
 	})
 
@@ -125,6 +285,11 @@ func (app *application) run(mux http.Handler) error {
 		IdleTimeout:  time.Minute,
 	}
 
+	app.shutdown = make(chan struct{})
+	srv.RegisterOnShutdown(func() {
+		close(app.shutdown)
+	})
+
 	shutdown := make(chan error)
 
 	go func() {
@@ -133,11 +298,15 @@ func (app *application) run(mux http.Handler) error {
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		s := <-quit
 
+		app.logger.Infow("signal cought", "signal", s.String())
+
+		// keep serving while load balancers notice the instance isn't ready
+		app.health.Drain()
+		time.Sleep(app.config.Health.DrainDelay)
+
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		app.logger.Infow("signal cought", "signal", s.String())
-
 		shutdown <- srv.Shutdown(ctx)
 	}()
 
@@ -149,6 +318,10 @@ func (app *application) run(mux http.Handler) error {
 	}
 
 	err = <-shutdown
+
+	// requests are done, stop what they left running
+	app.jobs.Stop()
+
 	if err != nil {
 		return err
 	}
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/shanisharrma/gopher-social/cmd/api/config"
 	"github.com/shanisharrma/gopher-social/internal/ratelimiter"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestRateLimiterMiddleware(t *testing.T) {
@@ -50,6 +58,345 @@ func TestRateLimiterMiddleware(t *testing.T) {
 			if resp.StatusCode != http.StatusTooManyRequests {
 				t.Errorf("expected status Too Many Requests; got %v", resp.Status)
 			}
+
+			if got := resp.Header.Get("Retry-After"); got != "1" {
+				t.Errorf("expected to retry after 1 second; got %q", got)
+			}
+		}
+
+		if got := resp.Header.Get("RateLimit-Limit"); got != "20" {
+			t.Errorf("expected a limit of 20; got %q", got)
+		}
+	}
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	cfg := config.Config{
+		Ratelimiter: ratelimiter.Config{
+			RequestPerTimeFrame: 20,
+			TimeFrame:           time.Minute,
+			Enabled:             true,
+			Policies: map[string]ratelimiter.Policy{
+				config.PolicyLogin: {RequestPerTimeFrame: 2, TimeFrame: time.Minute},
+			},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should apply the stricter policy of a route", func(t *testing.T) {
+		for i := range 3 {
+			req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token", strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = "10.0.0.1:1234"
+
+			rr := executeRequest(req, mux)
+
+			if i < 2 {
+				if rr.Code == http.StatusTooManyRequests {
+					t.Fatalf("request %d refused", i+1)
+				}
+				continue
+			}
+
+			checkResponseCode(t, http.StatusTooManyRequests, rr.Code)
+			if got := rr.Header().Get("Retry-After"); got != "60" {
+				t.Errorf("expected to retry after 60 seconds; got %q", got)
+			}
+			if got := rr.Header().Get("RateLimit-Remaining"); got != "0" {
+				t.Errorf("expected no remaining requests; got %q", got)
+			}
+		}
+	})
+
+	t.Run("should limit authenticated users apart from their address", func(t *testing.T) {
+		send := func(token string) *http.Response {
+			req, err := http.NewRequest(http.MethodGet, "/v1/health", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = "10.0.0.2:1234"
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			return executeRequest(req, mux).Result()
+		}
+
+		for range cfg.Ratelimiter.RequestPerTimeFrame {
+			send(testToken)
+		}
+
+		if resp := send(testToken); resp.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("expected the user to be limited; got %v", resp.Status)
+		}
+
+		if resp := send(""); resp.StatusCode != http.StatusOK {
+			t.Errorf("expected the address to keep its own allowance; got %v", resp.Status)
+		}
+	})
+}
+
+func TestClientAddress(t *testing.T) {
+	cfg := config.Config{
+		Ratelimiter: ratelimiter.Config{
+			RequestPerTimeFrame: 20,
+			TimeFrame:           time.Minute,
+			Enabled:             true,
+			Policies: map[string]ratelimiter.Policy{
+				config.PolicyLogin: {RequestPerTimeFrame: 1, TimeFrame: time.Minute},
+			},
+		},
+		TrustedProxies: []string{"192.0.2.0/24"},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	login := func(peer, forwardedFor string) int {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = peer
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should ignore the forwarded address of untrusted peers", func(t *testing.T) {
+		login("198.51.100.1:1234", "10.0.0.1")
+
+		if code := login("198.51.100.1:1234", "10.0.0.2"); code != http.StatusTooManyRequests {
+			t.Errorf("expected the peer to be limited whatever it forwards; got %d", code)
+		}
+	})
+
+	t.Run("should believe the forwarded address of trusted proxies", func(t *testing.T) {
+		login("192.0.2.1:1234", "10.0.0.3, 192.0.2.2")
+
+		if code := login("192.0.2.1:1234", "10.0.0.4"); code == http.StatusTooManyRequests {
+			t.Error("expected each forwarded client to have its own allowance")
+		}
+		if code := login("192.0.2.1:1234", "203.0.113.9, 10.0.0.3"); code != http.StatusTooManyRequests {
+			t.Errorf("expected the client closest to the proxy to be limited; got %d", code)
+		}
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Auth.Basic.User = "admin"
+	cfg.Auth.Basic.Pass = "pass"
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	req, err := http.NewRequest(http.MethodGet, "/v1/health", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executeRequest(req, mux)
+
+	t.Run("should require basic auth", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should report requests by route", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("admin", "pass")
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		want := `gophersocial_http_request_duration_seconds_count{method="GET",route="/v1/health",status="200"}`
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("expected the metrics to contain %s", want)
+		}
+	})
+
+	t.Run("should count responses without a status as OK", func(t *testing.T) {
+		r := chi.NewRouter()
+		r.Use(app.metricsMiddleware)
+		r.Get("/empty", func(w http.ResponseWriter, r *http.Request) {})
+
+		req, err := http.NewRequest(http.MethodGet, "/empty", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		executeRequest(req, r)
+
+		req, err = http.NewRequest(http.MethodGet, "/metrics", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("admin", "pass")
+
+		rr := executeRequest(req, mux)
+
+		want := `gophersocial_http_request_duration_seconds_count{method="GET",route="/empty",status="200"}`
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("expected the metrics to contain %s", want)
 		}
+	})
+}
+
+func TestAccessLog(t *testing.T) {
+	cfg := config.Config{}
+	cfg.AccessLog.SampleRatio = 1
+
+	core, logs := observer.New(zap.InfoLevel)
+	app := newTestApplication(t, cfg)
+	app.logger = zap.New(core).Sugar()
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
 	}
+
+	t.Run("should log the route, user and status without credentials", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		entries := logs.FilterMessage("request").TakeAll()
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 access log entry. got %d", len(entries))
+		}
+
+		fields := entries[0].ContextMap()
+		if fields["route"] != "/v1/users/{userID}" {
+			t.Errorf("expected the route pattern to be logged. got %v", fields["route"])
+		}
+		if _, ok := fields["user_id"]; !ok {
+			t.Error("expected the user to be logged")
+		}
+		if fields["status"] != int64(http.StatusOK) {
+			t.Errorf("expected status 200 to be logged. got %v", fields["status"])
+		}
+		if fields["request_id"] == "" {
+			t.Error("expected the request id to be logged")
+		}
+		if strings.Contains(fmt.Sprint(fields["headers"]), testToken) {
+			t.Error("expected the token to be redacted")
+		}
+	})
+
+	t.Run("should include the request id in error responses", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/abc", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		var envelope struct {
+			Error     string `json:"error"`
+			RequestID string `json:"request_id"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&envelope); err != nil {
+			t.Fatal(err)
+		}
+		if envelope.Error == "" || envelope.RequestID == "" {
+			t.Errorf("expected an error with a request id. got %+v", envelope)
+		}
+	})
+
+	t.Run("should skip unsampled successful requests", func(t *testing.T) {
+		app.config.AccessLog.SampleRatio = 0
+		t.Cleanup(func() { app.config.AccessLog.SampleRatio = 1 })
+		logs.TakeAll()
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/health", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		executeRequest(req, mux)
+
+		if n := logs.FilterMessage("request").Len(); n != 0 {
+			t.Errorf("expected no access log entry. got %d", n)
+		}
+	})
+}
+
+func TestHealthProbes(t *testing.T) {
+	app := newTestApplication(t, config.Config{})
+	app.health.Register("postgres", time.Second, func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	mux := app.mount()
+
+	t.Run("should be alive whatever its dependencies", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/health/live", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should not be ready when a dependency is down", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/health/ready", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusServiceUnavailable, rr.Code)
+		if !strings.Contains(rr.Body.String(), "connection refused") {
+			t.Errorf("expected the report to contain the failed check; got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("should not be rate limited", func(t *testing.T) {
+		app := newTestApplication(t, config.Config{
+			Ratelimiter: ratelimiter.Config{
+				RequestPerTimeFrame: 1,
+				TimeFrame:           time.Minute,
+				Enabled:             true,
+			},
+		})
+		mux := app.mount()
+
+		for range 3 {
+			req, err := http.NewRequest(http.MethodGet, "/v1/health/live", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := executeRequest(req, mux)
+
+			checkResponseCode(t, http.StatusOK, rr.Code)
+		}
+	})
 }
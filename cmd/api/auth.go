@@ -1,16 +1,21 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/shanisharrma/gopher-social/cmd/api/config"
 	"github.com/shanisharrma/gopher-social/internal/mailer"
+	"github.com/shanisharrma/gopher-social/internal/metrics"
 	"github.com/shanisharrma/gopher-social/internal/store"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type RegisterUserPayload struct {
@@ -93,7 +98,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 
 	ActivationURL := fmt.Sprintf("%s/confirm/%s", app.config.FrontendURL, plainToken)
 
-	isProdEnv := app.config.Env == "production"
+	isProdEnv := app.config.Env == config.EnvProduction
 	vars := struct {
 		Username      string
 		ActivationURL string
@@ -103,7 +108,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Send email
-	status, err := app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+	status, err := app.mailer.Send(ctx, mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
 	if err != nil {
 		app.logger.Errorw("error sending welcome email", "error", err)
 
@@ -117,6 +122,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	app.logger.Infow("Email sent", "status code", status)
+	metrics.Signups.Inc()
 
 	//return response
 	if err := app.jsonResponse(w, http.StatusCreated, "User registered", userWithToken); err != nil {
@@ -129,10 +135,68 @@ type CreateUserTokenPayload struct {
 	Password string `json:"password" validate:"required,min=8,max=72"`
 }
 
+// loginBackoff slows down repeated failed logins. Past free failures, each
+// one locks logins out for a delay doubling from base up to max, and reaching
+// lockoutAfter failures locks them out for lockout.
+type loginBackoff struct {
+	free         int
+	base         time.Duration
+	max          time.Duration
+	lockoutAfter int
+	lockout      time.Duration
+}
+
+var (
+	// accountBackoff applies to the failed logins of an email.
+	accountBackoff = loginBackoff{
+		free:         3,
+		base:         time.Second,
+		max:          time.Minute,
+		lockoutAfter: 10,
+		lockout:      time.Hour,
+	}
+	// ipBackoff applies to the failed logins from an address, which may be
+	// shared by many users, across emails.
+	ipBackoff = loginBackoff{
+		free:         20,
+		base:         time.Second,
+		max:          time.Minute,
+		lockoutAfter: 100,
+		lockout:      time.Hour,
+	}
+)
+
+func (b loginBackoff) delay(failures int) time.Duration {
+	if failures >= b.lockoutAfter {
+		return b.lockout
+	}
+
+	if failures <= b.free {
+		return 0
+	}
+
+	delay := b.base
+	for range failures - b.free - 1 {
+		delay *= 2
+		if delay >= b.max {
+			return b.max
+		}
+	}
+
+	return delay
+}
+
+// noUserPassword is compared with the password of logins to unknown emails,
+// so that they take as long as the others.
+var noUserPassword = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte(uuid.New().String()), bcrypt.DefaultCost)
+	return hash
+})
+
 // CreateToken godoc
 //
 //	@Summary		Login a user
-//	@Description	Login and create a token
+//	@Description	Login and create a token. Repeated failures lock logins to the account, and from the address, out for a while; the owner of the account is emailed when it gets locked.
 //	@Tags			Authentication
 //	@Accept			json
 //	@Product		json
@@ -140,6 +204,8 @@ type CreateUserTokenPayload struct {
 //	@Success		200		{string}	string					"Token"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error					"Account suspended"
+//	@Failure		429		{object}	error					"Too many failed logins"
 //	@Failure		500		{object}	error
 //	@Router			/authentication/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -156,15 +222,55 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	ctx := r.Context()
+	ip := clientIP(r)
+
+	// refuse logins locked out by previous failures, right or wrong
+	if app.rejectLockedLogin(w, r, payload.Email, ip) {
+		return
+	}
+
 	// fetch the user (check if the user exists) from the payload
-	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
-	if err != nil {
-		switch err {
-		case store.ErrNotFound:
-			app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid credentials"))
-		default:
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var matches bool
+	if user != nil {
+		matches, err = user.Password.Matches(payload.Password)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	} else {
+		_ = bcrypt.CompareHashAndPassword(noUserPassword(), []byte(payload.Password))
+	}
+
+	if !matches {
+		if err := app.recordLoginFailure(ctx, payload.Email, ip, user); err != nil {
 			app.internalServerError(w, r, err)
+			return
 		}
+
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid credentials"))
+		return
+	}
+
+	if err := app.store.LoginThrottles.Reset(ctx, store.LoginScopeAccount, payload.Email); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the user found by email comes without its suspension
+	account, err := app.getUser(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if app.rejectSuspended(w, r, account) {
 		return
 	}
 
@@ -189,3 +295,67 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 }
+
+// rejectLockedLogin writes an error response and returns true when logins to
+// email, or from ip, are locked out.
+func (app *application) rejectLockedLogin(w http.ResponseWriter, r *http.Request, email, ip string) bool {
+	var lockedUntil time.Time
+	for scope, key := range map[string]string{store.LoginScopeAccount: email, store.LoginScopeIP: ip} {
+		throttle, err := app.store.LoginThrottles.Get(r.Context(), scope, key)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return true
+		}
+
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(lockedUntil) {
+			lockedUntil = *throttle.LockedUntil
+		}
+	}
+
+	if lockedUntil.IsZero() {
+		return false
+	}
+
+	app.loginLockedResponse(w, r, time.Until(lockedUntil))
+	return true
+}
+
+// recordLoginFailure counts a failed login to email from ip. When it locks
+// the account of user out, the user is emailed about it.
+func (app *application) recordLoginFailure(ctx context.Context, email, ip string, user *store.User) error {
+	if _, err := app.store.LoginThrottles.RecordFailure(ctx, store.LoginScopeIP, ip, ipBackoff.delay); err != nil {
+		return err
+	}
+
+	throttle, err := app.store.LoginThrottles.RecordFailure(ctx, store.LoginScopeAccount, email, accountBackoff.delay)
+	if err != nil {
+		return err
+	}
+
+	if user == nil || throttle.Failures != accountBackoff.lockoutAfter || throttle.LockedUntil == nil {
+		return nil
+	}
+
+	vars := struct {
+		Username    string
+		Failures    int
+		IP          string
+		LockedUntil string
+	}{
+		Username:    user.Username,
+		Failures:    throttle.Failures,
+		IP:          ip,
+		LockedUntil: throttle.LockedUntil.Format(time.RFC1123),
+	}
+
+	// the response doesn't wait on the mail server, so that its timing
+	// doesn't tell which emails belong to users
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if err := app.sendEmail(ctx, mailer.LoginAlertTemplate, user, vars); err != nil {
+			app.logger.Errorw("error sending login alert", "user", user.ID, "error", err)
+		}
+	}()
+
+	return nil
+}
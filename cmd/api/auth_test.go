@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/cmd/api/config"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+func TestCreateToken(t *testing.T) {
+	app := newTestApplication(t, config.Config{})
+	mux := app.mount()
+
+	login := func(email, password string) *http.Response {
+		body := `{"email": "` + email + `", "password": "` + password + `"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = "10.0.0.1:1234"
+
+		return executeRequest(req, mux).Result()
+	}
+
+	t.Run("should check the password", func(t *testing.T) {
+		if resp := login("gopher@example.com", "wrong-password"); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status Unauthorized; got %v", resp.Status)
+		}
+
+		if resp := login("gopher@example.com", store.MockUserPassword); resp.StatusCode != http.StatusCreated {
+			t.Errorf("expected status Created; got %v", resp.Status)
+		}
+	})
+
+	t.Run("should lock the account out after repeated failures", func(t *testing.T) {
+		for range accountBackoff.free + 1 {
+			if resp := login("lock@example.com", "wrong-password"); resp.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("expected status Unauthorized; got %v", resp.Status)
+			}
+		}
+
+		// even with the right password
+		resp := login("LOCK@example.com", store.MockUserPassword)
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("expected status Too Many Requests; got %v", resp.Status)
+		}
+
+		if got := resp.Header.Get("Retry-After"); got != "1" {
+			t.Errorf("expected to retry after 1 second; got %q", got)
+		}
+
+		// other accounts aren't affected
+		if resp := login("gopher@example.com", store.MockUserPassword); resp.StatusCode != http.StatusCreated {
+			t.Errorf("expected status Created; got %v", resp.Status)
+		}
+	})
+}
+
+func TestLoginBackoff(t *testing.T) {
+	b := loginBackoff{free: 2, base: time.Second, max: 5 * time.Second, lockoutAfter: 8, lockout: time.Hour}
+
+	for failures, want := range []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second, time.Hour} {
+		if got := b.delay(failures); got != want {
+			t.Errorf("expected a delay of %v after %d failures. got %v", want, failures, got)
+		}
+	}
+}
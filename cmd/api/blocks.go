@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+// targetUserFromParam resolves the {userID} url param into an existing user
+// other than the authenticated one. It writes the error response itself and
+// returns nil when the request should stop.
+func (app *application) targetUserFromParam(w http.ResponseWriter, r *http.Request) *store.User {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil
+	}
+
+	if userID == getUserFromCtx(r).ID {
+		app.badRequestResponse(w, r, errors.New("target user must be someone else"))
+		return nil
+	}
+
+	user, err := app.getUser(r.Context(), userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil
+	}
+
+	return user
+}
+
+// BlockUser godoc
+//
+//	@Summary		Block a user
+//	@Description	Blocks a user, removing follow relationships in both directions
+//	@Tags			Users
+//	@Produce		json
+//	@Param			id	path		int		true	"User ID"
+//	@Success		204	{string}	string	"User blocked"
+//	@Failure		400	{object}	error	"Payload missing"
+//	@Failure		404	{object}	error	"Not found"
+//	@Failure		500	{object}	error	"An error occured"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	target := app.targetUserFromParam(w, r)
+	if target == nil {
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := app.store.Blocks.Block(r.Context(), user.ID, target.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "User blocked", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UnblockUser godoc
+//
+//	@Summary		Unblock a user
+//	@Description	Removes a user from the block list
+//	@Tags			Users
+//	@Produce		json
+//	@Param			id	path		int		true	"User ID"
+//	@Success		204	{string}	string	"User unblocked"
+//	@Failure		400	{object}	error	"Payload missing"
+//	@Failure		500	{object}	error	"An error occured"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/unblock [put]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	blockedID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := app.store.Blocks.Unblock(r.Context(), user.ID, blockedID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "User unblocked", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// MuteUser godoc
+//
+//	@Summary		Mute a user
+//	@Description	Hides a user's posts from the feed without notifying them
+//	@Tags			Users
+//	@Produce		json
+//	@Param			id	path		int		true	"User ID"
+//	@Success		204	{string}	string	"User muted"
+//	@Failure		400	{object}	error	"Payload missing"
+//	@Failure		404	{object}	error	"Not found"
+//	@Failure		500	{object}	error	"An error occured"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	target := app.targetUserFromParam(w, r)
+	if target == nil {
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := app.store.Mutes.Mute(r.Context(), user.ID, target.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "User muted", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UnmuteUser godoc
+//
+//	@Summary		Unmute a user
+//	@Description	Removes a user from the mute list
+//	@Tags			Users
+//	@Produce		json
+//	@Param			id	path		int		true	"User ID"
+//	@Success		204	{string}	string	"User unmuted"
+//	@Failure		400	{object}	error	"Payload missing"
+//	@Failure		500	{object}	error	"An error occured"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/unmute [put]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	mutedID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := app.store.Mutes.Unmute(r.Context(), user.ID, mutedID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "User unmuted", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetBlockedUsers godoc
+//
+//	@Summary		Fetches blocked users
+//	@Description	Fetches the users blocked by the authenticated user
+//	@Tags			Users
+//	@Produce		json
+//	@Success		200	{object}	[]store.User	"Blocked users fetched"
+//	@Failure		500	{object}	error			"An error occured"
+//	@Security		ApiKeyAuth
+//	@Router			/users/blocks [get]
+func (app *application) getBlockedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	users, err := app.store.Blocks.GetBlocked(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Blocked users fetched", users); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetMutedUsers godoc
+//
+//	@Summary		Fetches muted users
+//	@Description	Fetches the users muted by the authenticated user
+//	@Tags			Users
+//	@Produce		json
+//	@Success		200	{object}	[]store.User	"Muted users fetched"
+//	@Failure		500	{object}	error			"An error occured"
+//	@Security		ApiKeyAuth
+//	@Router			/users/mutes [get]
+func (app *application) getMutedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	users, err := app.store.Mutes.GetMuted(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Muted users fetched", users); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shanisharrma/gopher-social/internal/events"
+	"github.com/shanisharrma/gopher-social/internal/metrics"
+	"github.com/shanisharrma/gopher-social/internal/notifications"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+type CreateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+// CreateComment godoc
+//
+//	@Summary		Comment on a post
+//	@Description	Adds a comment to a post the user is allowed to see
+//	@Tags			Posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Post ID"
+//	@Param			payload	body		CreateCommentPayload	true	"Comment payload"
+//	@Success		201		{object}	store.Comment			"Comment created"
+//	@Failure		400		{object}	error					"Payload missing"
+//	@Failure		401		{object}	error					"Unauthorized"
+//	@Failure		404		{object}	error					"Not found"
+//	@Failure		500		{object}	error					"An error occured"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments [post]
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateCommentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+	ctx := r.Context()
+
+	// blocked users and non followers of private accounts can't comment
+	allowed, err := app.canViewPost(ctx, user, post)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	comment, err := app.addComment(ctx, user, post, payload.Content)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, "Comment created", comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteComment godoc
+//
+//	@Summary		Deletes a comment
+//	@Description	Deletes a comment by its author, the author of the post, a moderator or a moderator of the community of the post
+//	@Tags			Posts
+//	@Produce		json
+//	@Param			id			path		int		true	"Post ID"
+//	@Param			commentID	path		int		true	"Comment ID"
+//	@Success		204			{string}	string	"Comment deleted"
+//	@Failure		400			{object}	error	"Invalid comment ID"
+//	@Failure		401			{object}	error	"Unauthorized"
+//	@Failure		403			{object}	error	"Forbidden"
+//	@Failure		404			{object}	error	"Not found"
+//	@Failure		500			{object}	error	"An error occured"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments/{commentID} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid comment id"))
+		return
+	}
+
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+	ctx := r.Context()
+
+	comment, err := app.store.Comments.GetById(ctx, commentID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if comment.PostID != post.ID {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	if user.ID != comment.UserID && user.ID != post.UserID {
+		allowed, err := app.checkRolePrecedence(ctx, user, "moderator")
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			allowed, err = app.isCommunityModerator(ctx, post, user)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+		}
+
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	if err := app.removeComment(ctx, comment); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Comment deleted", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type deletedComment struct {
+	ID     int64 `json:"id"`
+	PostID int64 `json:"post_id"`
+	UserID int64 `json:"user_id"`
+}
+
+// addComment saves a comment written by user and lets the author of the post
+// and the clients following the post live know about it. Callers check that
+// user can see the post.
+func (app *application) addComment(ctx context.Context, user *store.User, post *store.Post, content string) (*store.Comment, error) {
+	comment := &store.Comment{
+		UserID:  user.ID,
+		PostID:  post.ID,
+		Content: content,
+		User: store.User{
+			ID:       user.ID,
+			Username: user.Username,
+		},
+	}
+
+	if err := app.store.Comments.Create(ctx, comment); err != nil {
+		return nil, err
+	}
+	metrics.CommentsCreated.Inc()
+
+	app.publishToPost(ctx, post.ID, events.TypeCommentCreated, comment)
+	app.publish(ctx, post.UserID, events.TypeCommentCreated, comment)
+	app.notify(ctx, notifications.Event{
+		Type:        notifications.TypeComment,
+		RecipientID: post.UserID,
+		ActorID:     user.ID,
+		EntityType:  "post",
+		EntityID:    post.ID,
+	})
+	app.notifyMentions(ctx, user.ID, post, comment.MentionedIDs)
+
+	return comment, nil
+}
+
+// removeComment deletes a comment and lets the clients following its post live
+// know about it. Callers check that the user may delete it.
+func (app *application) removeComment(ctx context.Context, comment *store.Comment) error {
+	if err := app.store.Comments.Delete(ctx, comment.ID); err != nil {
+		return err
+	}
+
+	app.publishToPost(ctx, comment.PostID, events.TypeCommentDeleted, deletedComment{
+		ID:     comment.ID,
+		PostID: comment.PostID,
+		UserID: comment.UserID,
+	})
+
+	return nil
+}
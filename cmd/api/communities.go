@@ -0,0 +1,647 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+type communityKey string
+
+var communityCtx communityKey = "community"
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+type CreateCommunityPayload struct {
+	Slug        string `json:"slug"        validate:"required,min=3,max=50"`
+	Name        string `json:"name"        validate:"required,max=100"`
+	Description string `json:"description" validate:"max=500"`
+	Visibility  string `json:"visibility"  validate:"omitempty,oneof=public invite_only"`
+}
+
+type InviteToCommunityPayload struct {
+	UserID int64 `json:"user_id" validate:"required,gt=0"`
+}
+
+type SetCommunityRolePayload struct {
+	Role string `json:"role" validate:"required,oneof=moderator member"`
+}
+
+// CreateCommunity godoc
+//
+//	@Summary		Creates a community
+//	@Description	Creates a community owned by the authenticated user. Anyone can join public communities, invite only ones need an invitation from a moderator.
+//	@Tags			Communities
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateCommunityPayload	true	"Community"
+//	@Success		201		{object}	store.Community
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error	"Slug taken"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/communities [post]
+func (app *application) createCommunityHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateCommunityPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	slug := strings.ToLower(payload.Slug)
+	if !slugPattern.MatchString(slug) {
+		app.badRequestResponse(w, r, errors.New("slug must be letters, digits and single dashes"))
+		return
+	}
+
+	visibility := payload.Visibility
+	if visibility == "" {
+		visibility = store.CommunityPublic
+	}
+
+	user := getUserFromCtx(r)
+
+	community := &store.Community{
+		Slug:        slug,
+		Name:        payload.Name,
+		Description: payload.Description,
+		Visibility:  visibility,
+		OwnerID:     user.ID,
+	}
+
+	if err := app.store.Communities.Create(r.Context(), community); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, "Community created", community); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetCommunity godoc
+//
+//	@Summary		Fetches a community
+//	@Description	Fetches a community with the role of the authenticated user in it
+//	@Tags			Communities
+//	@Produce		json
+//	@Param			communityID	path		int	true	"Community ID"
+//	@Success		200			{object}	store.Community
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/communities/{communityID} [get]
+func (app *application) getCommunityHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, "Community fetched", getCommunityFromCtx(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// JoinCommunity godoc
+//
+//	@Summary		Joins a community
+//	@Description	Joins a public community, or an invite only one the authenticated user was invited to
+//	@Tags			Communities
+//	@Produce		json
+//	@Param			communityID	path		int		true	"Community ID"
+//	@Success		204			{string}	string	"Community joined"
+//	@Failure		403			{object}	error	"Not invited"
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error	"Already a member"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/communities/{communityID}/join [put]
+func (app *application) joinCommunityHandler(w http.ResponseWriter, r *http.Request) {
+	community := getCommunityFromCtx(r)
+	user := getUserFromCtx(r)
+
+	if community.ViewerRole != "" {
+		app.conflictResponse(w, r, store.ErrConflict)
+		return
+	}
+
+	if err := app.store.Communities.Join(r.Context(), community, user.ID); err != nil {
+		switch err {
+		case store.ErrNotInvited:
+			app.forbiddenResponse(w, r)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Community joined", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// LeaveCommunity godoc
+//
+//	@Summary		Leaves a community
+//	@Description	Leaves a community. Owners can't leave their community.
+//	@Tags			Communities
+//	@Produce		json
+//	@Param			communityID	path		int		true	"Community ID"
+//	@Success		204			{string}	string	"Community left"
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/communities/{communityID}/leave [put]
+func (app *application) leaveCommunityHandler(w http.ResponseWriter, r *http.Request) {
+	community := getCommunityFromCtx(r)
+	user := getUserFromCtx(r)
+
+	switch community.ViewerRole {
+	case "":
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	case store.CommunityRoleOwner:
+		app.badRequestResponse(w, r, errors.New("owners can't leave their community"))
+		return
+	}
+
+	if err := app.store.Communities.Leave(r.Context(), community.ID, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Community left", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// InviteToCommunity godoc
+//
+//	@Summary		Invites a user to a community
+//	@Description	Invites a user to a community, letting them join it even if it is invite only. Only moderators of the community can invite.
+//	@Tags			Communities
+//	@Accept			json
+//	@Produce		json
+//	@Param			communityID	path		int							true	"Community ID"
+//	@Param			payload		body		InviteToCommunityPayload	true	"Invitee"
+//	@Success		204			{string}	string						"User invited"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error	"Already invited or a member"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/communities/{communityID}/invitations [post]
+func (app *application) inviteToCommunityHandler(w http.ResponseWriter, r *http.Request) {
+	var payload InviteToCommunityPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	community := getCommunityFromCtx(r)
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	if !store.CommunityRoleAtLeast(community.ViewerRole, store.CommunityRoleModerator) {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	invitee, err := app.getUser(ctx, payload.UserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// blocked users can't reach each other this way either
+	blocked, err := app.store.Blocks.IsBlocked(ctx, user.ID, invitee.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if blocked {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	if err := app.store.Communities.Invite(ctx, community.ID, invitee.ID, user.ID); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "User invited", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetCommunityInvitations godoc
+//
+//	@Summary		Fetches community invitations
+//	@Description	Fetches the communities the authenticated user was invited to and hasn't joined yet
+//	@Tags			Communities
+//	@Produce		json
+//	@Success		200	{object}	[]store.Community
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/communities/invitations [get]
+func (app *application) getCommunityInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	communities, err := app.store.Communities.GetInvitations(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Invitations fetched", communities); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetCommunityMembers godoc
+//
+//	@Summary		Fetches community members
+//	@Description	Fetches the members of a community, most recent first. The members of invite only communities are only listed to other members.
+//	@Tags			Communities
+//	@Produce		json
+//	@Param			communityID	path		int		true	"Community ID"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			cursor		query		string	false	"Cursor of the next page"
+//	@Success		200			{object}	store.CommunityMemberPage
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/communities/{communityID}/members [get]
+func (app *application) getCommunityMembersHandler(w http.ResponseWriter, r *http.Request) {
+	community := app.viewableCommunity(w, r)
+	if community == nil {
+		return
+	}
+
+	q, ok := app.cursorQuery(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := app.store.Communities.GetMembers(r.Context(), community.ID, q)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Members fetched", page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// SetCommunityRole godoc
+//
+//	@Summary		Changes the role of a community member
+//	@Description	Makes a member a moderator of the community, or a plain member again. Only the owner can change roles.
+//	@Tags			Communities
+//	@Accept			json
+//	@Produce		json
+//	@Param			communityID	path		int						true	"Community ID"
+//	@Param			userID		path		int						true	"User ID"
+//	@Param			payload		body		SetCommunityRolePayload	true	"Role"
+//	@Success		204			{string}	string					"Role changed"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/communities/{communityID}/members/{userID}/role [put]
+func (app *application) setCommunityRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var payload SetCommunityRolePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	community := getCommunityFromCtx(r)
+
+	if community.ViewerRole != store.CommunityRoleOwner {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	member := app.targetUserFromParam(w, r)
+	if member == nil {
+		return
+	}
+
+	if err := app.store.Communities.SetRole(r.Context(), community.ID, member.ID, payload.Role); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Role changed", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RemoveCommunityMember godoc
+//
+//	@Summary		Removes a community member
+//	@Description	Removes a member from a community. Moderators can remove members, and only the owner can remove moderators.
+//	@Tags			Communities
+//	@Produce		json
+//	@Param			communityID	path		int		true	"Community ID"
+//	@Param			userID		path		int		true	"User ID"
+//	@Success		204			{string}	string	"Member removed"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/communities/{communityID}/members/{userID} [delete]
+func (app *application) removeCommunityMemberHandler(w http.ResponseWriter, r *http.Request) {
+	community := getCommunityFromCtx(r)
+	ctx := r.Context()
+
+	if !store.CommunityRoleAtLeast(community.ViewerRole, store.CommunityRoleModerator) {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	member := app.targetUserFromParam(w, r)
+	if member == nil {
+		return
+	}
+
+	role, err := app.store.Communities.GetRole(ctx, community.ID, member.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if role == "" {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	// moderators can't remove each other, nor the owner
+	if store.CommunityRoleAtLeast(role, community.ViewerRole) {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if err := app.store.Communities.RemoveMember(ctx, community.ID, member.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Member removed", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetCommunityPosts godoc
+//
+//	@Summary		Fetches the feed of a community
+//	@Description	Fetches the posts shared in a community. The posts of invite only communities are only shown to members.
+//	@Tags			Communities
+//	@Produce		json
+//	@Param			communityID	path		int		true	"Community ID"
+//	@Param			since		query		string	false	"Since"
+//	@Param			until		query		string	false	"Until"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			offset		query		int		false	"Offset"
+//	@Param			sort		query		string	false	"Sort"
+//	@Param			tags		query		string	false	"Tags"
+//	@Param			search		query		string	false	"Search"
+//	@Success		200			{object}	[]store.PostWithMetadata
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/communities/{communityID}/posts [get]
+func (app *application) getCommunityPostsHandler(w http.ResponseWriter, r *http.Request) {
+	community := app.viewableCommunity(w, r)
+	if community == nil {
+		return
+	}
+
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	posts, err := app.store.Posts.GetByCommunity(r.Context(), community.ID, user.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Posts fetched", posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetUserCommunities godoc
+//
+//	@Summary		Fetches the communities of a user
+//	@Description	Fetches the communities a user belongs to. Invite only communities are only listed to their members.
+//	@Tags			Users
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Success		200		{object}	[]store.Community
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/communities [get]
+func (app *application) getUserCommunitiesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	viewer := getUserFromCtx(r)
+	ctx := r.Context()
+
+	user, err := app.getUser(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	allowed, err := app.canViewContent(ctx, viewer, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	communities, err := app.store.Communities.GetForUser(ctx, user.ID, viewer.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Communities fetched", communities); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// viewableCommunity returns the community of the request if the user may see
+// its content, writing the error response itself otherwise.
+func (app *application) viewableCommunity(w http.ResponseWriter, r *http.Request) *store.Community {
+	community := getCommunityFromCtx(r)
+
+	allowed, err := app.canViewCommunity(r.Context(), getUserFromCtx(r), community)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return nil
+	}
+
+	if !allowed {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return nil
+	}
+
+	return community
+}
+
+// canViewCommunity reports whether viewer may see the members and posts of a
+// community fetched for them. Invite only communities are open to their
+// members and to global moderators.
+func (app *application) canViewCommunity(ctx context.Context, viewer *store.User, community *store.Community) (bool, error) {
+	if community.Visibility == store.CommunityPublic || community.ViewerRole != "" {
+		return true, nil
+	}
+
+	return app.checkRolePrecedence(ctx, viewer, "moderator")
+}
+
+// isCommunityModerator reports whether user moderates the community of a
+// post. Posts outside of communities have no community moderators.
+func (app *application) isCommunityModerator(ctx context.Context, post *store.Post, user *store.User) (bool, error) {
+	if post.CommunityID == nil {
+		return false, nil
+	}
+
+	role, err := app.store.Communities.GetRole(ctx, *post.CommunityID, user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return store.CommunityRoleAtLeast(role, store.CommunityRoleModerator), nil
+}
+
+// canModeratePost reports whether user moderates post, site wide or in the
+// community it was shared in.
+func (app *application) canModeratePost(ctx context.Context, user *store.User, post *store.Post) (bool, error) {
+	allowed, err := app.checkRolePrecedence(ctx, user, "moderator")
+	if err != nil || allowed {
+		return allowed, err
+	}
+
+	return app.isCommunityModerator(ctx, post, user)
+}
+
+func (app *application) communityContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "communityID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("invalid community id"))
+			return
+		}
+
+		user := getUserFromCtx(r)
+		ctx := r.Context()
+
+		community, err := app.store.Communities.GetById(ctx, id, user.ID)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, communityCtx, community)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommunityFromCtx(r *http.Request) *store.Community {
+	community, _ := r.Context().Value(communityCtx).(*store.Community)
+	return community
+}
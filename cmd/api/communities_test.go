@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/shanisharrma/gopher-social/cmd/api/config"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+func TestInviteOnlyCommunity(t *testing.T) {
+	app := newTestApplication(t, config.Config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not let users join without an invitation", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/communities/1/join", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("should hide members from non members", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/communities/1/members", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("should not let non members invite", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/communities/1/invitations", strings.NewReader(`{"user_id": 3}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+}
+
+type communityPostStore struct {
+	store.MockPostStore
+}
+
+func (s communityPostStore) GetById(ctx context.Context, postID int64) (*store.Post, error) {
+	communityID := int64(1)
+	return &store.Post{ID: postID, UserID: 1, CommunityID: &communityID}, nil
+}
+
+type moderatedCommunityStore struct {
+	store.MockCommunityStore
+	role string
+}
+
+func (s moderatedCommunityStore) GetRole(ctx context.Context, communityID, userID int64) (string, error) {
+	return s.role, nil
+}
+
+func TestCommunityModeration(t *testing.T) {
+	app := newTestApplication(t, config.Config{})
+	app.store.Posts = communityPostStore{}
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hide := func(role string) int {
+		app.store.Communities = moderatedCommunityStore{role: role}
+		mux := app.mount()
+
+		req, err := http.NewRequest(http.MethodPatch, "/v1/posts/7", strings.NewReader(`{"is_hidden": true}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should let community moderators hide community posts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, hide(store.CommunityRoleModerator))
+	})
+
+	t.Run("should not let members hide community posts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, hide(store.CommunityRoleMember))
+	})
+}
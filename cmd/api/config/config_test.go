@@ -0,0 +1,151 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	t.Setenv("MAILTRAP_API_KEY", "mailtrap-key")
+
+	t.Run("should apply the file, the environment then the flags", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "config.yaml")
+		err := os.WriteFile(file, []byte("addr: :9000\nexternal_url: api.example.com\nfrontend_url: example.com\n"), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("EXTERNAL_URL", "env.example.com")
+		t.Setenv("FRONTEND_URL", "env.example.com")
+
+		cfg, opts, err := Load([]string{"-config", file, "-frontend-url", "flag.example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if opts.File != file {
+			t.Errorf("expected the config file %s; got %s", file, opts.File)
+		}
+		if cfg.Addr != ":9000" {
+			t.Errorf("expected the address of the file; got %s", cfg.Addr)
+		}
+		if cfg.ApiUrl != "env.example.com" {
+			t.Errorf("expected the environment to override the file; got %s", cfg.ApiUrl)
+		}
+		if cfg.FrontendURL != "flag.example.com" {
+			t.Errorf("expected the flag to override the environment; got %s", cfg.FrontendURL)
+		}
+	})
+
+	t.Run("should reject unknown settings in the file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(file, []byte("adress: :9000\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := Load([]string{"-config", file}); err == nil {
+			t.Error("expected an error for the misspelled setting")
+		}
+	})
+
+	t.Run("should read secrets from files", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "secret")
+		if err := os.WriteFile(file, []byte("secret-from-a-file\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("AUTH_TOKEN_SECRET_FILE", file)
+
+		cfg, _, err := Load(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cfg.Auth.Token.Secret != "secret-from-a-file" {
+			t.Errorf("expected the secret of the file; got %q", cfg.Auth.Token.Secret)
+		}
+	})
+
+	t.Run("should report values of the environment that don't parse", func(t *testing.T) {
+		t.Setenv("AUTH_TOKEN_EXP", "3 days")
+		t.Setenv("REDIS_ENABLED", "yes please")
+
+		_, _, err := Load(nil)
+		if err == nil {
+			t.Fatal("expected the invalid values to be reported")
+		}
+
+		for _, key := range []string{"AUTH_TOKEN_EXP", "REDIS_ENABLED"} {
+			if !strings.Contains(err.Error(), key) {
+				t.Errorf("expected %s to be reported; got %v", key, err)
+			}
+		}
+	})
+
+	t.Run("should still read the drain delay in seconds", func(t *testing.T) {
+		t.Setenv("HEALTH_DRAIN_DELAY_SECONDS", "12")
+
+		cfg, _, err := Load(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cfg.Health.DrainDelay != 12*time.Second {
+			t.Errorf("expected a drain delay of 12s; got %v", cfg.Health.DrainDelay)
+		}
+	})
+
+	t.Run("should read the trusted proxies", func(t *testing.T) {
+		t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.0.2.1")
+
+		cfg, _, err := Load(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		proxies, err := cfg.Proxies()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(proxies) != 2 || proxies[1].String() != "192.0.2.1/32" {
+			t.Errorf("expected a range and a single address; got %v", proxies)
+		}
+
+		t.Setenv("TRUSTED_PROXIES", "10.0.0.0/33")
+		if _, _, err := Load(nil); err == nil || !strings.Contains(err.Error(), "trusted_proxies") {
+			t.Errorf("expected the invalid range to be reported; got %v", err)
+		}
+	})
+
+	t.Run("should refuse default secrets in production", func(t *testing.T) {
+		_, _, err := Load([]string{"-env", EnvProduction})
+		if err == nil {
+			t.Fatal("expected the default secrets to be refused")
+		}
+
+		for _, setting := range []string{"auth.token.secret", "auth.basic.pass", "db.addr"} {
+			if !strings.Contains(err.Error(), setting) {
+				t.Errorf("expected %s to be reported; got %v", setting, err)
+			}
+		}
+	})
+}
+
+func TestPrint(t *testing.T) {
+	cfg := Default()
+	cfg.Db.Addr = "postgres://admin:dbpassword@db/social"
+	cfg.Auth.Token.Secret = "tokensecret"
+	cfg.Mail.Mailtrap.ApiKey = "mailtrapkey"
+
+	var out strings.Builder
+	if err := cfg.Print(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{"dbpassword", "tokensecret", "mailtrapkey"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("expected %s to be redacted", secret)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"io"
+	"net/url"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// Print writes cfg with its secrets redacted, in the format of the config
+// file.
+func (cfg Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// Redacted is cfg with its secrets hidden, to be printed or logged.
+func (cfg Config) Redacted() Config {
+	for _, secret := range []*string{
+		&cfg.RedisCfg.Pw,
+		&cfg.Mail.Sendgrid.ApiKey,
+		&cfg.Mail.Mailtrap.ApiKey,
+		&cfg.Auth.Basic.Pass,
+		&cfg.Auth.Token.Secret,
+	} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+
+	// the database address carries its password, in a URL or a list of
+	// key=value settings
+	if u, err := url.Parse(cfg.Db.Addr); err == nil && u.Scheme != "" {
+		cfg.Db.Addr = u.Redacted()
+	} else if cfg.Db.Addr != "" {
+		cfg.Db.Addr = redacted
+	}
+
+	return cfg
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/env"
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile overrides cfg with the settings of a YAML file. Settings missing
+// from the file keep their current value.
+func loadFile(cfg *Config, path string) error {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+	default:
+		return fmt.Errorf("config file %s: only YAML files are supported", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// loadEnv overrides cfg with the environment variables that are set. Secrets
+// can also be read from the file named by their variable with a _FILE suffix.
+// Values that don't parse are kept in cfg for Validate to report.
+func loadEnv(cfg *Config) error {
+	parsed := func(err error) {
+		if err != nil {
+			cfg.envErrs = append(cfg.envErrs, err)
+		}
+	}
+	integer := func(key string, fallback int) int {
+		val, err := env.Parse(key, fallback, strconv.Atoi)
+		parsed(err)
+		return val
+	}
+	boolean := func(key string, fallback bool) bool {
+		val, err := env.Parse(key, fallback, strconv.ParseBool)
+		parsed(err)
+		return val
+	}
+	float := func(key string, fallback float64) float64 {
+		val, err := env.Parse(key, fallback, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+		parsed(err)
+		return val
+	}
+	duration := func(key string, fallback time.Duration) time.Duration {
+		val, err := env.Parse(key, fallback, time.ParseDuration)
+		parsed(err)
+		return val
+	}
+
+	cfg.Env = env.GetString("ENV", cfg.Env)
+	cfg.Addr = env.GetString("ADDR", cfg.Addr)
+	cfg.ApiUrl = env.GetString("EXTERNAL_URL", cfg.ApiUrl)
+	cfg.FrontendURL = env.GetString("FRONTEND_URL", cfg.FrontendURL)
+
+	cfg.Db.MaxOpenConns = integer("DB_MAX_OPEN_CONNS", cfg.Db.MaxOpenConns)
+	cfg.Db.MaxIdleConns = integer("DB_MAX_IDLE_CONNS", cfg.Db.MaxIdleConns)
+	cfg.Db.MaxIdleTime = env.GetString("DB_MAX_IDLE_TIME", cfg.Db.MaxIdleTime)
+
+	// REDDIS_ADDR is the misspelled name this used to be read from
+	cfg.RedisCfg.Addr = env.GetString("REDIS_ADDR", env.GetString("REDDIS_ADDR", cfg.RedisCfg.Addr))
+	cfg.RedisCfg.DB = integer("REDIS_DB", cfg.RedisCfg.DB)
+	cfg.RedisCfg.Enabled = boolean("REDIS_ENABLED", cfg.RedisCfg.Enabled)
+
+	cfg.Mail.Exp = duration("MAIL_EXP", cfg.Mail.Exp)
+	cfg.Mail.FromEmail = env.GetString("FROM_EMAIL", cfg.Mail.FromEmail)
+	cfg.Mail.Mailtrap.Username = env.GetString("MAILTRAP_USERNAME", cfg.Mail.Mailtrap.Username)
+
+	cfg.Auth.Basic.User = env.GetString("BASIC_AUTH_USER", cfg.Auth.Basic.User)
+	cfg.Auth.Token.Exp = duration("AUTH_TOKEN_EXP", cfg.Auth.Token.Exp)
+
+	secrets := []struct {
+		key   string
+		value *string
+	}{
+		{"DB_ADDR", &cfg.Db.Addr},
+		{"REDIS_PW", &cfg.RedisCfg.Pw},
+		{"SENDGRID_API_KEY", &cfg.Mail.Sendgrid.ApiKey},
+		{"MAILTRAP_API_KEY", &cfg.Mail.Mailtrap.ApiKey},
+		{"BASIC_AUTH_PASS", &cfg.Auth.Basic.Pass},
+		{"AUTH_TOKEN_SECRET", &cfg.Auth.Token.Secret},
+	}
+	for _, secret := range secrets {
+		value, err := env.GetSecret(secret.key, *secret.value)
+		if err != nil {
+			return err
+		}
+		*secret.value = value
+	}
+
+	rl := &cfg.Ratelimiter
+	rl.RequestPerTimeFrame = integer("RATELIMITER_REQUESTS_COUNT", rl.RequestPerTimeFrame)
+	rl.Enabled = boolean("RATE_LIMITER_ENABLED", rl.Enabled)
+	rl.Strategy = env.GetString("RATE_LIMITER_STRATEGY", rl.Strategy)
+	rl.FailurePolicy = env.GetString("RATE_LIMITER_FAILURE_POLICY", rl.FailurePolicy)
+	for name, key := range map[string]string{
+		PolicyLogin:      "RATELIMITER_LOGIN_COUNT",
+		PolicyRegister:   "RATELIMITER_REGISTER_COUNT",
+		PolicyCreatePost: "RATELIMITER_CREATE_POST_COUNT",
+	} {
+		if policy, ok := rl.Policies[name]; ok {
+			policy.RequestPerTimeFrame = integer(key, policy.RequestPerTimeFrame)
+			rl.Policies[name] = policy
+		}
+	}
+
+	cfg.Trending.Enabled = boolean("TRENDING_ENABLED", cfg.Trending.Enabled)
+
+	cfg.Tracing.Enabled = boolean("TRACING_ENABLED", cfg.Tracing.Enabled)
+	cfg.Tracing.Endpoint = env.GetString("TRACING_ENDPOINT", cfg.Tracing.Endpoint)
+	cfg.Tracing.Insecure = boolean("TRACING_INSECURE", cfg.Tracing.Insecure)
+	cfg.Tracing.SampleRatio = float("TRACING_SAMPLE_RATIO", cfg.Tracing.SampleRatio)
+	cfg.Tracing.ServiceName = env.GetString("TRACING_SERVICE_NAME", cfg.Tracing.ServiceName)
+
+	cfg.AccessLog.SampleRatio = float("ACCESS_LOG_SAMPLE_RATIO", cfg.AccessLog.SampleRatio)
+
+	cfg.Health.CheckMailer = boolean("HEALTH_CHECK_MAILER", cfg.Health.CheckMailer)
+	// HEALTH_DRAIN_DELAY_SECONDS is the number of seconds this used to be
+	// read from
+	drainDelay := time.Duration(integer("HEALTH_DRAIN_DELAY_SECONDS", int(cfg.Health.DrainDelay/time.Second))) * time.Second
+	cfg.Health.DrainDelay = duration("HEALTH_DRAIN_DELAY", drainDelay)
+
+	cfg.Features.RefreshInterval = duration("FEATURES_REFRESH_INTERVAL", cfg.Features.RefreshInterval)
+
+	if proxies := env.GetString("TRUSTED_PROXIES", ""); proxies != "" {
+		cfg.TrustedProxies = strings.Split(strings.ReplaceAll(proxies, " ", ""), ",")
+	}
+
+	return nil
+}
+
+// parseFlags parses the command line into opts, and returns the overrides
+// of the flags that were set, to apply last.
+func parseFlags(args []string, opts *Options) ([]func(*Config), error) {
+	flags := flag.NewFlagSet("api", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of %s:\n\n", flags.Name())
+		fmt.Fprintln(flags.Output(), "Settings come from the defaults, the config file, the environment and")
+		fmt.Fprintln(flags.Output(), "these flags, each overriding the previous ones.")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+
+	flags.StringVar(&opts.File, "config", env.GetString("CONFIG_FILE", ""), "YAML config `file` (CONFIG_FILE)")
+	flags.BoolVar(&opts.PrintConfig, "print-config", false, "print the configuration, with secrets redacted, and exit")
+
+	var overrides []func(*Config)
+	str := func(name, usage string, field func(*Config) *string) {
+		flags.Func(name, usage, func(value string) error {
+			overrides = append(overrides, func(cfg *Config) { *field(cfg) = value })
+			return nil
+		})
+	}
+	boolean := func(name, usage string, field func(*Config) *bool) {
+		flags.BoolFunc(name, usage, func(value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			overrides = append(overrides, func(cfg *Config) { *field(cfg) = b })
+			return nil
+		})
+	}
+
+	str("env", "`name` of the environment: development, staging or production (ENV)", func(cfg *Config) *string { return &cfg.Env })
+	str("addr", "`address` to listen on (ADDR)", func(cfg *Config) *string { return &cfg.Addr })
+	str("external-url", "external `host` of the API (EXTERNAL_URL)", func(cfg *Config) *string { return &cfg.ApiUrl })
+	str("frontend-url", "`url` of the frontend (FRONTEND_URL)", func(cfg *Config) *string { return &cfg.FrontendURL })
+	str("redis-addr", "`address` of redis (REDIS_ADDR)", func(cfg *Config) *string { return &cfg.RedisCfg.Addr })
+	boolean("redis", "use redis (REDIS_ENABLED)", func(cfg *Config) *bool { return &cfg.RedisCfg.Enabled })
+	boolean("rate-limiter", "limit request rates (RATE_LIMITER_ENABLED)", func(cfg *Config) *bool { return &cfg.Ratelimiter.Enabled })
+	boolean("trending", "compute trending content (TRENDING_ENABLED)", func(cfg *Config) *bool { return &cfg.Trending.Enabled })
+	boolean("tracing", "export traces (TRACING_ENABLED)", func(cfg *Config) *bool { return &cfg.Tracing.Enabled })
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return overrides, nil
+}
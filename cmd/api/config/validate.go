@@ -0,0 +1,96 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/ratelimiter"
+)
+
+// minSecretLength is the shortest token secret accepted in production.
+const minSecretLength = 32
+
+// Validate reports every invalid setting at once, so a deployment can be
+// fixed in one go. Production refuses the development defaults of secrets.
+func (cfg Config) Validate() error {
+	errs := append([]error{}, cfg.envErrs...)
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	switch cfg.Env {
+	case EnvDevelopment, EnvStaging, EnvProduction:
+	default:
+		check(false, "env: unknown environment %q", cfg.Env)
+	}
+
+	check(cfg.Addr != "", "addr: required")
+	check(cfg.ApiUrl != "", "external_url: required")
+
+	check(cfg.Db.Addr != "", "db.addr: required")
+	check(cfg.Db.MaxOpenConns > 0, "db.max_open_conns: must be positive")
+	check(cfg.Db.MaxIdleConns >= 0, "db.max_idle_conns: must not be negative")
+	_, err := time.ParseDuration(cfg.Db.MaxIdleTime)
+	check(err == nil, "db.max_idle_time: invalid duration %q", cfg.Db.MaxIdleTime)
+
+	if cfg.RedisCfg.Enabled {
+		check(cfg.RedisCfg.Addr != "", "redis.addr: required when redis is enabled")
+	}
+
+	check(cfg.Mail.Exp > 0, "mail.exp: must be positive")
+	check(cfg.Mail.Mailtrap.ApiKey != "", "mail.mailtrap.api_key: required")
+
+	check(cfg.Auth.Token.Secret != "", "auth.token.secret: required")
+	check(cfg.Auth.Token.Exp > 0, "auth.token.exp: must be positive")
+	check(cfg.Auth.Token.Iss != "", "auth.token.iss: required")
+	check(cfg.Auth.Basic.User != "" && cfg.Auth.Basic.Pass != "", "auth.basic: user and pass required")
+
+	if cfg.Env == EnvProduction {
+		check(cfg.Auth.Token.Secret != defaultTokenSecret, "auth.token.secret: the default secret can't be used in production")
+		check(len(cfg.Auth.Token.Secret) >= minSecretLength, "auth.token.secret: must be at least %d characters in production", minSecretLength)
+		check(cfg.Auth.Basic.Pass != defaultBasicPass, "auth.basic.pass: the default password can't be used in production")
+		check(cfg.Db.Addr != defaultDbAddr, "db.addr: the default database can't be used in production")
+	}
+
+	if rl := cfg.Ratelimiter; rl.Enabled {
+		check(rl.RequestPerTimeFrame > 0 && rl.TimeFrame > 0, "rate_limiter: requests and time_frame must be positive")
+		switch rl.Strategy {
+		case "", ratelimiter.StrategyFixedWindow, ratelimiter.StrategySlidingWindow, ratelimiter.StrategyTokenBucket:
+		default:
+			check(false, "rate_limiter.strategy: unknown strategy %q", rl.Strategy)
+		}
+		switch rl.FailurePolicy {
+		case "", ratelimiter.FailureLocal, ratelimiter.FailureOpen, ratelimiter.FailureClosed:
+		default:
+			check(false, "rate_limiter.failure_policy: unknown policy %q", rl.FailurePolicy)
+		}
+		for name, p := range rl.Policies {
+			check(p.RequestPerTimeFrame > 0 && p.TimeFrame > 0, "rate_limiter.policies.%s: requests and time_frame must be positive", name)
+		}
+	}
+
+	if cfg.Trending.Enabled {
+		check(cfg.Trending.Interval > 0, "trending.interval: must be positive")
+	}
+
+	check(cfg.Tracing.SampleRatio >= 0 && cfg.Tracing.SampleRatio <= 1, "tracing.sample_ratio: must be between 0 and 1")
+	check(cfg.AccessLog.SampleRatio >= 0 && cfg.AccessLog.SampleRatio <= 1, "access_log.sample_ratio: must be between 0 and 1")
+
+	check(cfg.Health.CheckTimeout > 0, "health.check_timeout: must be positive")
+	check(cfg.Health.CacheTTL >= 0, "health.cache_ttl: must not be negative")
+	check(cfg.Health.DrainDelay >= 0, "health.drain_delay: must not be negative")
+
+	check(cfg.Features.RefreshInterval > 0, "features.refresh_interval: must be positive")
+
+	_, err = cfg.Proxies()
+	check(err == nil, "trusted_proxies: %v", err)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+
+	return nil
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shanisharrma/gopher-social/internal/events"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+type conversationKey string
+
+var conversationCtx conversationKey = "conversation"
+
+type CreateConversationPayload struct {
+	UserIDs []int64 `json:"user_ids" validate:"required,min=1,max=9,dive,gt=0"`
+	Title   string  `json:"title"    validate:"max=100"`
+}
+
+type SendMessagePayload struct {
+	Content string `json:"content" validate:"required,max=2000"`
+}
+
+type MarkConversationReadPayload struct {
+	MessageID int64 `json:"message_id" validate:"gte=0"`
+}
+
+type conversationRead struct {
+	ConversationID    int64 `json:"conversation_id"`
+	UserID            int64 `json:"user_id"`
+	LastReadMessageID int64 `json:"last_read_message_id"`
+}
+
+// CreateConversation godoc
+//
+//	@Summary		Starts a conversation
+//	@Description	Starts a one-to-one conversation with a single user, returning the existing one if any, or a group conversation with several. Users who blocked, or were blocked by, the sender, and private accounts with no follow relationship with them can't be added.
+//	@Tags			Messages
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateConversationPayload	true	"Members"
+//	@Success		200		{object}	store.Conversation			"Existing conversation"
+//	@Success		201		{object}	store.Conversation			"Conversation created"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations [post]
+func (app *application) createConversationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateConversationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	seen := map[int64]bool{user.ID: true}
+	memberIDs := []int64{}
+	for _, id := range payload.UserIDs {
+		if !seen[id] {
+			seen[id] = true
+			memberIDs = append(memberIDs, id)
+		}
+	}
+
+	if len(memberIDs) == 0 {
+		app.badRequestResponse(w, r, errors.New("a conversation needs someone else"))
+		return
+	}
+
+	for _, id := range memberIDs {
+		member, err := app.getUser(ctx, id)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		allowed, err := app.canMessage(ctx, user, member)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	if len(memberIDs) == 1 {
+		conversation, created, err := app.store.Conversations.GetOrCreateDirect(ctx, user.ID, memberIDs[0])
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+
+		if err := app.jsonResponse(w, status, "Conversation started", conversation); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	conversation := &store.Conversation{Title: payload.Title}
+	if err := app.store.Conversations.CreateGroup(ctx, conversation, user.ID, memberIDs); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, "Conversation started", conversation); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetConversations godoc
+//
+//	@Summary		Fetches conversations
+//	@Description	Fetches the authenticated user's conversations, most recently active first, with their unread counts
+//	@Tags			Messages
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	store.ConversationPage
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations [get]
+func (app *application) getConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	q, ok := app.cursorQuery(w, r)
+	if !ok {
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	page, err := app.store.Conversations.ListForUser(r.Context(), user.ID, q)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Conversations fetched", page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetConversation godoc
+//
+//	@Summary		Fetches a conversation
+//	@Description	Fetches a conversation of the authenticated user
+//	@Tags			Messages
+//	@Produce		json
+//	@Param			conversationID	path		int	true	"Conversation ID"
+//	@Success		200				{object}	store.Conversation
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID} [get]
+func (app *application) getConversationHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, "Conversation fetched", getConversationFromCtx(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetMessages godoc
+//
+//	@Summary		Fetches messages
+//	@Description	Fetches the messages of a conversation, newest first
+//	@Tags			Messages
+//	@Produce		json
+//	@Param			conversationID	path		int		true	"Conversation ID"
+//	@Param			limit			query		int		false	"Limit"
+//	@Param			cursor			query		string	false	"Cursor of the next page"
+//	@Success		200				{object}	store.MessagePage
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/messages [get]
+func (app *application) getMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	q, ok := app.cursorQuery(w, r)
+	if !ok {
+		return
+	}
+
+	user := getUserFromCtx(r)
+	conversation := getConversationFromCtx(r)
+
+	page, err := app.store.Conversations.GetMessages(r.Context(), conversation.ID, user.ID, q)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Messages fetched", page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// SendMessage godoc
+//
+//	@Summary		Sends a message
+//	@Description	Sends a message to a conversation. One-to-one conversations are closed once either user blocks the other.
+//	@Tags			Messages
+//	@Accept			json
+//	@Produce		json
+//	@Param			conversationID	path		int					true	"Conversation ID"
+//	@Param			payload			body		SendMessagePayload	true	"Message"
+//	@Success		201				{object}	store.Message
+//	@Failure		400				{object}	error
+//	@Failure		403				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/messages [post]
+func (app *application) sendMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var payload SendMessagePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	conversation := getConversationFromCtx(r)
+	ctx := r.Context()
+
+	// members in a block relationship with the sender don't get the message
+	recipients := []int64{}
+	for _, member := range conversation.Members {
+		if member.UserID == user.ID {
+			continue
+		}
+
+		blocked, err := app.store.Blocks.IsBlocked(ctx, user.ID, member.UserID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !blocked {
+			recipients = append(recipients, member.UserID)
+		}
+	}
+
+	if !conversation.IsGroup && len(recipients) == 0 {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	message := &store.Message{
+		ConversationID: conversation.ID,
+		SenderID:       user.ID,
+		Content:        payload.Content,
+	}
+
+	if err := app.store.Conversations.AddMessage(ctx, message); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the sender's other devices get it too
+	for _, userID := range append(recipients, user.ID) {
+		app.publish(ctx, userID, events.TypeMessage, message)
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, "Message sent", message); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// MarkConversationRead godoc
+//
+//	@Summary		Marks a conversation as read
+//	@Description	Marks the messages of a conversation as read up to message_id, or all of them when it is 0 or left out
+//	@Tags			Messages
+//	@Accept			json
+//	@Produce		json
+//	@Param			conversationID	path		int							true	"Conversation ID"
+//	@Param			payload			body		MarkConversationReadPayload	true	"Last message read"
+//	@Success		204				{string}	string						"Conversation read"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/read [put]
+func (app *application) markConversationReadHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MarkConversationReadPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	conversation := getConversationFromCtx(r)
+	ctx := r.Context()
+
+	lastRead, err := app.store.Conversations.MarkRead(ctx, conversation.ID, user.ID, payload.MessageID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// read receipts for the other members, and unread counts for the user's
+	// other devices
+	read := conversationRead{
+		ConversationID:    conversation.ID,
+		UserID:            user.ID,
+		LastReadMessageID: lastRead,
+	}
+	for _, member := range conversation.Members {
+		app.publish(ctx, member.UserID, events.TypeConversationRead, read)
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Conversation read", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// canMessage reports whether sender may start a conversation with recipient.
+// Users in a block relationship can't, and private accounts only hear from
+// users they follow or who follow them.
+func (app *application) canMessage(ctx context.Context, sender, recipient *store.User) (bool, error) {
+	blocked, err := app.store.Blocks.IsBlocked(ctx, sender.ID, recipient.ID)
+	if err != nil || blocked {
+		return false, err
+	}
+
+	if !recipient.IsPrivate {
+		return true, nil
+	}
+
+	following, err := app.store.Followers.IsFollowing(ctx, sender.ID, recipient.ID)
+	if err != nil || following {
+		return following, err
+	}
+
+	return app.store.Followers.IsFollowing(ctx, recipient.ID, sender.ID)
+}
+
+// cursorQuery reads the pagination of a request, writing the error response
+// itself when it is invalid.
+func (app *application) cursorQuery(w http.ResponseWriter, r *http.Request) (store.CursorQuery, bool) {
+	q, err := store.CursorQuery{Limit: 20}.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return q, false
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return q, false
+	}
+
+	return q, true
+}
+
+func (app *application) conversationContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("invalid conversation id"))
+			return
+		}
+
+		user := getUserFromCtx(r)
+		ctx := r.Context()
+
+		// conversations of others look like they don't exist
+		conversation, err := app.store.Conversations.GetForMember(ctx, id, user.ID)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, conversationCtx, conversation)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getConversationFromCtx(r *http.Request) *store.Conversation {
+	conversation, _ := r.Context().Value(conversationCtx).(*store.Conversation)
+	return conversation
+}
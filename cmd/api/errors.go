@@ -1,11 +1,25 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/shanisharrma/gopher-social/internal/store"
+	"github.com/shanisharrma/gopher-social/internal/tracing"
+	"go.uber.org/zap"
 )
 
+// requestLogger is the logger of a request, which ties its entries to the
+// trace of the request.
+func (app *application) requestLogger(r *http.Request) *zap.SugaredLogger {
+	return tracing.Logger(r.Context(), app.logger)
+}
+
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Errorw(
+	app.requestLogger(r).Errorw(
 		"internal server",
 		"method",
 		r.Method,
@@ -15,17 +29,17 @@ func (app *application) internalServerError(w http.ResponseWriter, r *http.Reque
 		err.Error(),
 	)
 
-	WriteJSONError(w, http.StatusInternalServerError, err.Error())
+	WriteJSONError(w, r, http.StatusInternalServerError, err.Error())
 }
 
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Warnf("bad request", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	app.requestLogger(r).Warnw("bad request", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 
-	WriteJSONError(w, http.StatusBadRequest, err.Error())
+	WriteJSONError(w, r, http.StatusBadRequest, err.Error())
 }
 
 func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Errorw(
+	app.requestLogger(r).Errorw(
 		"conflict response error",
 		"method",
 		r.Method,
@@ -35,38 +49,72 @@ func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request,
 		err.Error(),
 	)
 
-	WriteJSONError(w, http.StatusConflict, err.Error())
+	WriteJSONError(w, r, http.StatusConflict, err.Error())
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Warnf("not found", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	app.requestLogger(r).Warnw("not found", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 
-	WriteJSONError(w, http.StatusNotFound, err.Error())
+	WriteJSONError(w, r, http.StatusNotFound, err.Error())
 }
 
 func (app *application) unauthorizedErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Warnf("unathorized error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	app.requestLogger(r).Warnw("unathorized error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 
-	WriteJSONError(w, http.StatusUnauthorized, "unauthorized")
+	WriteJSONError(w, r, http.StatusUnauthorized, "unauthorized")
 }
 
 func (app *application) unauthorizedBasicErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Warnf("unathorized basic error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	app.requestLogger(r).Warnw("unathorized basic error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 	w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
 
-	WriteJSONError(w, http.StatusUnauthorized, "unauthorized")
+	WriteJSONError(w, r, http.StatusUnauthorized, "unauthorized")
 }
 
 func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request) {
-	app.logger.Warnf("forbidden error", "method", r.Method, "path", r.URL.Path)
+	app.requestLogger(r).Warnw("forbidden error", "method", r.Method, "path", r.URL.Path)
+
+	WriteJSONError(w, r, http.StatusForbidden, "forbidden: user does't have access")
+}
+
+func (app *application) featureDisabledResponse(w http.ResponseWriter, r *http.Request, flag string) {
+	app.requestLogger(r).Warnw("feature disabled", "method", r.Method, "path", r.URL.Path, "flag", flag)
 
-	WriteJSONError(w, http.StatusForbidden, "forbidden: user does't have access")
+	WriteJSONError(w, r, http.StatusNotFound, "this feature is not available")
 }
 
-func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter string) {
-	app.logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path)
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.requestLogger(r).Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path)
 
-	w.Header().Set("Retry-After", retryAfter)
+	seconds := ceilSeconds(retryAfter)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	WriteJSONError(w, r, http.StatusTooManyRequests, fmt.Sprintf("rate limit exceeded, retry after %d seconds", seconds))
+}
+
+func (app *application) loginLockedResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.requestLogger(r).Warnw("login locked out", "method", r.Method, "path", r.URL.Path, "ip", clientIP(r))
+
+	seconds := ceilSeconds(retryAfter)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	WriteJSONError(w, r, http.StatusTooManyRequests, fmt.Sprintf("too many failed logins, retry after %d seconds", seconds))
+}
 
-	WriteJSONError(w, http.StatusTooManyRequests, "rate limit exceed, retry after: "+retryAfter)
+func (app *application) suspendedResponse(w http.ResponseWriter, r *http.Request, suspension *store.Suspension) {
+	app.requestLogger(r).Warnw("suspended user", "method", r.Method, "path", r.URL.Path, "user", suspension.UserID)
+
+	type envelope struct {
+		Error          string    `json:"error"`
+		Reason         string    `json:"reason"`
+		SuspendedUntil time.Time `json:"suspended_until"`
+		RequestID      string    `json:"request_id,omitempty"`
+	}
+
+	writeJSON(w, http.StatusForbidden, &envelope{
+		Error:          "account suspended",
+		Reason:         suspension.Reason,
+		SuspendedUntil: suspension.EndsAt,
+		RequestID:      middleware.GetReqID(r.Context()),
+	})
 }
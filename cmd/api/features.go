@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shanisharrma/gopher-social/internal/features"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+// featureEnabled reports whether a flag is on for the user of a request.
+// Requests not authenticated yet only get the flags rolled out to everyone.
+func (app *application) featureEnabled(r *http.Request, key string) bool {
+	var userID int64
+	if user, ok := r.Context().Value(userCtx).(*store.User); ok {
+		userID = user.ID
+	}
+
+	return app.features.Enabled(key, userID)
+}
+
+// requireFeature hides a route while its flag is off.
+func (app *application) requireFeature(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !app.featureEnabled(r, key) {
+				app.featureDisabledResponse(w, r, key)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+var featureKeyPattern = regexp.MustCompile(`^[a-z0-9_]{1,100}$`)
+
+type SetFeatureFlagPayload struct {
+	Enabled bool `json:"enabled"`
+	// Rollout is the percentage of users the flag is on for, 100 by default.
+	Rollout     *int   `json:"rollout" validate:"omitempty,min=0,max=100"`
+	Description string `json:"description" validate:"max=500"`
+}
+
+// GetFeatureFlags godoc
+//
+//	@Summary		Lists the feature flags
+//	@Description	Lists the feature flags in effect, including the defaults of those never set
+//	@Tags			Admin
+//	@Produce		json
+//	@Success		200	{object}	[]store.FeatureFlag
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/features [get]
+func (app *application) getFeatureFlagsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.features.Refresh(r.Context()); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Feature flags fetched", app.features.All()); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// SetFeatureFlag godoc
+//
+//	@Summary		Turns a feature on or off
+//	@Description	Creates or changes a feature flag, for everyone or a percentage of users. Every instance applies the change within seconds, and it is recorded in the audit log.
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			key		path		string					true	"Flag key"
+//	@Param			payload	body		SetFeatureFlagPayload	true	"Flag payload"
+//	@Success		200		{object}	store.FeatureFlag
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/features/{key} [put]
+func (app *application) setFeatureFlagHandler(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	if !featureKeyPattern.MatchString(key) {
+		app.badRequestResponse(w, r, errors.New("key must be up to 100 lowercase letters, digits and underscores"))
+		return
+	}
+
+	var payload SetFeatureFlagPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Rollout != nil && *payload.Rollout < 100 && features.IsKillSwitch(key) {
+		app.badRequestResponse(w, r, fmt.Errorf("%s can only be on or off for everyone", key))
+		return
+	}
+
+	flag := &store.FeatureFlag{
+		Key:         key,
+		Enabled:     payload.Enabled,
+		Rollout:     100,
+		Description: payload.Description,
+	}
+	if payload.Rollout != nil {
+		flag.Rollout = *payload.Rollout
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.FeatureFlags.Set(ctx, flag); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the flag is saved: if announcing it fails, the other instances only
+	// pick it up on their next periodic refresh
+	if err := app.features.Changed(ctx); err != nil {
+		app.requestLogger(r).Errorw("error announcing feature flag change", "flag", key, "error", err)
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Feature flag updated", flag); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/shanisharrma/gopher-social/cmd/api/config"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+type adminUserStore struct {
+	store.MockUserStore
+}
+
+func (s adminUserStore) GetById(ctx context.Context, userID int64) (*store.User, error) {
+	return &store.User{ID: userID, Role: store.Role{Name: "admin", Level: 3}}, nil
+}
+
+func TestFeatureFlags(t *testing.T) {
+	app := newTestApplication(t, config.Config{})
+	app.store.Users = adminUserStore{}
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	register := func() int {
+		body := `{"username": "gopher", "email": "gopher@example.com", "password": "password123"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/user", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should reject invalid flags", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/admin/features/registration", strings.NewReader(`{"enabled": true, "rollout": 150}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should refuse partial rollouts of kill switches", func(t *testing.T) {
+		for _, key := range []string{"rate_limiter", "registration"} {
+			req, err := http.NewRequest(http.MethodPut, "/v1/admin/features/"+key, strings.NewReader(`{"enabled": true, "rollout": 50}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+
+			rr := executeRequest(req, mux)
+
+			checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should turn a feature off at runtime", func(t *testing.T) {
+		if code := register(); code == http.StatusNotFound {
+			t.Fatal("expected registration to be open by default")
+		}
+
+		req, err := http.NewRequest(http.MethodPut, "/v1/admin/features/registration", strings.NewReader(`{"enabled": false}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		checkResponseCode(t, http.StatusNotFound, register())
+	})
+}
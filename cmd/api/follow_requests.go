@@ -0,0 +1,159 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shanisharrma/gopher-social/internal/metrics"
+	"github.com/shanisharrma/gopher-social/internal/notifications"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+// requestFollow creates a pending follow request to a private account.
+func (app *application) requestFollow(w http.ResponseWriter, r *http.Request, requesterID, userID int64) {
+	ctx := r.Context()
+
+	following, err := app.store.Followers.IsFollowing(ctx, requesterID, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if following {
+		app.conflictResponse(w, r, store.ErrConflict)
+		return
+	}
+
+	if err := app.store.FollowRequests.Create(ctx, requesterID, userID); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.notify(ctx, notifications.Event{
+		Type:        notifications.TypeFollowRequest,
+		RecipientID: userID,
+		ActorID:     requesterID,
+		EntityType:  "user",
+		EntityID:    userID,
+	})
+
+	if err := app.jsonResponse(w, http.StatusAccepted, "Follow request sent", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetFollowRequests godoc
+//
+//	@Summary		Fetches incoming follow requests
+//	@Description	Fetches the pending follow requests sent to the authenticated user
+//	@Tags			Users
+//	@Produce		json
+//	@Success		200	{object}	[]store.FollowRequest	"Follow requests fetched"
+//	@Failure		401	{object}	error					"Unauthorized"
+//	@Failure		500	{object}	error					"An error occured"
+//	@Security		ApiKeyAuth
+//	@Router			/users/follow-requests [get]
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	requests, err := app.store.FollowRequests.GetIncoming(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Follow requests fetched", requests); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ApproveFollowRequest godoc
+//
+//	@Summary		Approves a follow request
+//	@Description	Approves the pending follow request sent by the given user
+//	@Tags			Users
+//	@Produce		json
+//	@Param			id	path		int		true	"Requester ID"
+//	@Success		204	{string}	string	"Follow request approved"
+//	@Failure		400	{object}	error	"Payload missing"
+//	@Failure		404	{object}	error	"Not found"
+//	@Failure		500	{object}	error	"An error occured"
+//	@Security		ApiKeyAuth
+//	@Router			/users/follow-requests/{id}/approve [put]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	requesterID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.FollowRequests.Approve(ctx, requesterID, user.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	metrics.Follows.Inc()
+
+	app.notify(ctx, notifications.Event{
+		Type:        notifications.TypeFollowAccepted,
+		RecipientID: requesterID,
+		ActorID:     user.ID,
+		EntityType:  "user",
+		EntityID:    user.ID,
+	})
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Follow request approved", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RejectFollowRequest godoc
+//
+//	@Summary		Rejects a follow request
+//	@Description	Rejects the pending follow request sent by the given user
+//	@Tags			Users
+//	@Produce		json
+//	@Param			id	path		int		true	"Requester ID"
+//	@Success		204	{string}	string	"Follow request rejected"
+//	@Failure		400	{object}	error	"Payload missing"
+//	@Failure		404	{object}	error	"Not found"
+//	@Failure		500	{object}	error	"An error occured"
+//	@Security		ApiKeyAuth
+//	@Router			/users/follow-requests/{id}/reject [put]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	requesterID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.FollowRequests.Delete(r.Context(), requesterID, user.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Follow request rejected", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
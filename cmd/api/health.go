@@ -2,6 +2,8 @@ package main
 
 import (
 	"net/http"
+
+	"github.com/shanisharrma/gopher-social/internal/health"
 )
 
 // HealthCheck godoc
@@ -20,3 +22,41 @@ func (app *application) healthCheckHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 }
+
+// Liveness godoc
+//
+//	@Summary		Check liveness
+//	@Description	Reports that the process is running and serving requests, without checking its dependencies
+//	@Tags			Ops
+//	@Produce		json
+//	@Success		200	{object}	health.Report
+//	@Router			/health/live [get]
+func (app *application) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	report := health.Report{Status: health.StatusUp, Checks: map[string]health.Result{}}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Server is alive", report); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Readiness godoc
+//
+//	@Summary		Check readiness
+//	@Description	Checks the dependencies needed to serve requests, and fails while the server shuts down
+//	@Tags			Ops
+//	@Produce		json
+//	@Success		200	{object}	health.Report
+//	@Failure		503	{object}	health.Report
+//	@Router			/health/ready [get]
+func (app *application) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := app.health.Check(r.Context())
+
+	status, message := http.StatusOK, "Server is ready"
+	if report.Status != health.StatusUp {
+		status, message = http.StatusServiceUnavailable, "Server is not ready"
+	}
+
+	if err := app.jsonResponse(w, status, message, report); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// jobs tracks the goroutines that outlive the requests starting them, so
+// that shutdown can stop them and wait for them.
+type jobs struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newJobs() *jobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobs{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn with a context cancelled on Stop.
+func (j *jobs) Go(fn func(ctx context.Context)) {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		fn(j.ctx)
+	}()
+}
+
+// Stop cancels the jobs and waits for them to return.
+func (j *jobs) Stop() {
+	j.cancel()
+	j.wg.Wait()
+}
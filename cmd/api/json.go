@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -33,12 +34,15 @@ func readJSON(w http.ResponseWriter, r *http.Request, data any) error {
 	return decoder.Decode(data)
 }
 
-func WriteJSONError(w http.ResponseWriter, status int, message string) error {
+// WriteJSONError writes an error envelope, with the ID of the request so that
+// clients can quote it when reporting problems.
+func WriteJSONError(w http.ResponseWriter, r *http.Request, status int, message string) error {
 	type envelope struct {
-		Error string `json:"error"`
+		Error     string `json:"error"`
+		RequestID string `json:"request_id,omitempty"`
 	}
 
-	return writeJSON(w, status, &envelope{Error: message})
+	return writeJSON(w, status, &envelope{Error: message, RequestID: middleware.GetReqID(r.Context())})
 }
 
 func (app *application) jsonResponse(
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/shanisharrma/gopher-social/internal/events"
+	"github.com/shanisharrma/gopher-social/internal/ratelimiter"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+const (
+	liveWriteWait      = 10 * time.Second
+	livePongWait       = 60 * time.Second
+	livePingInterval   = livePongWait / 2
+	liveMaxMessageSize = 4096
+
+	// each connection may send liveMessageLimit messages per window
+	liveMessageLimit  = 10
+	liveMessageWindow = 5 * time.Second
+)
+
+// Messages sent by live clients.
+const (
+	liveTyping  = "typing"
+	liveComment = "comment"
+)
+
+var errLiveRateLimited = errors.New("rate limit exceeded, slow down")
+
+type liveMessage struct {
+	Type    string `json:"type"`
+	Content string `json:"content"`
+}
+
+type liveTypingData struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+}
+
+type livePresenceData struct {
+	Count int64 `json:"count"`
+}
+
+type liveErrorData struct {
+	Error string `json:"error"`
+}
+
+// LivePost godoc
+//
+//	@Summary		Follows a post live
+//	@Description	Upgrades to a WebSocket relaying comments created and deleted on the post, typing indicators and the number of connected clients. Clients send {"type": "typing"} and {"type": "comment", "content": "..."} messages. Browsers pass the token as the subprotocols "access_token" and the token.
+//	@Tags			Posts
+//	@Param			id	path		int		true	"Post ID"
+//	@Success		101	{string}	string	"Switching protocols"
+//	@Failure		401	{object}	error	"Unauthorized"
+//	@Failure		404	{object}	error	"Not found"
+//	@Failure		500	{object}	error	"An error occured"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/live [get]
+func (app *application) livePostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	allowed, err := app.canViewPost(r.Context(), user, post)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin:  app.checkOrigin,
+		Subprotocols: []string{tokenProtocol},
+	}
+
+	// the upgrader writes the error response itself
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	topic := events.PostTopic(post.ID)
+	sub, err := app.events.Subscribe(ctx, topic)
+	if err != nil {
+		app.logger.Errorw("error subscribing to post", "post", post.ID, "error", err)
+		return
+	}
+	defer sub.Close()
+
+	clientID := uuid.NewString()
+	app.joinLive(ctx, post.ID, clientID)
+	defer app.leaveLive(post.ID, clientID)
+
+	replies := make(chan events.Event)
+	go func() {
+		defer cancel()
+		app.readLive(ctx, conn, user, post, replies)
+	}()
+
+	ping := time.NewTicker(livePingInterval)
+	defer ping.Stop()
+
+	// blocked between the viewer and other users, looked up once per user
+	blocked := map[int64]bool{}
+
+	for {
+		var event events.Event
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-app.shutdown:
+			closeLive(conn, websocket.CloseGoingAway, "server shutting down")
+			return
+		case <-ping.C:
+			// keep the client counted, without telling everyone again
+			if _, err := app.presence.Join(ctx, topic, clientID); err != nil {
+				app.logger.Errorw("error refreshing presence", "post", post.ID, "error", err)
+			}
+
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteWait))
+			if err != nil {
+				return
+			}
+			continue
+		case reply := <-replies:
+			event = reply
+		case published, ok := <-sub.C:
+			if !ok {
+				// dropped by the broker, the client reconnects
+				closeLive(conn, websocket.CloseTryAgainLater, "too many events, reconnect")
+				return
+			}
+
+			skip, err := app.hideFromViewer(ctx, user, published, blocked)
+			if err != nil {
+				app.logger.Errorw("error filtering live event", "post", post.ID, "error", err)
+				return
+			}
+
+			if skip {
+				continue
+			}
+			event = published
+		}
+
+		_ = conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+		if err := conn.WriteJSON(event); err != nil {
+			return
+		}
+	}
+}
+
+// readLive handles the messages of a live client until the connection fails
+// or ctx is done. Replies meant for this client only are sent to replies.
+func (app *application) readLive(
+	ctx context.Context,
+	conn *websocket.Conn,
+	user *store.User,
+	post *store.Post,
+	replies chan<- events.Event,
+) {
+	limiter := ratelimiter.NewFixedWindowRateLimiter(liveMessageLimit, liveMessageWindow)
+	defer limiter.Stop()
+
+	conn.SetReadLimit(liveMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(livePongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(livePongWait))
+	})
+
+	reply := func(err error) {
+		raw, _ := json.Marshal(liveErrorData{Error: err.Error()})
+
+		select {
+		case replies <- events.Event{Type: "error", Data: raw}:
+		case <-ctx.Done():
+		}
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if res := limiter.Allow(""); !res.Allowed {
+			reply(errLiveRateLimited)
+			continue
+		}
+
+		var msg liveMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			reply(errors.New("malformed message"))
+			continue
+		}
+
+		switch msg.Type {
+		case liveTyping:
+			typing := events.Event{Type: events.TypeTyping, Transient: true}
+			app.publishTo(ctx, events.PostTopic(post.ID), typing, liveTypingData{
+				UserID:   user.ID,
+				Username: user.Username,
+			})
+		case liveComment:
+			if err := Validate.Struct(CreateCommentPayload{Content: msg.Content}); err != nil {
+				reply(err)
+				continue
+			}
+
+			if _, err := app.addComment(ctx, user, post, msg.Content); err != nil {
+				app.logger.Errorw("error adding live comment", "post", post.ID, "error", err)
+				reply(errors.New("the comment could not be saved"))
+			}
+		default:
+			reply(errors.New("unknown message type"))
+		}
+	}
+}
+
+// hideFromViewer reports whether a post event comes from a user who blocked,
+// or was blocked by, the viewer. Results are kept in blocked.
+func (app *application) hideFromViewer(
+	ctx context.Context,
+	viewer *store.User,
+	event events.Event,
+	blocked map[int64]bool,
+) (bool, error) {
+	if event.Type != events.TypeCommentCreated && event.Type != events.TypeTyping {
+		return false, nil
+	}
+
+	var author struct {
+		UserID int64 `json:"user_id"`
+	}
+	if err := json.Unmarshal(event.Data, &author); err != nil {
+		return false, err
+	}
+
+	// clients see their own comments, not their own typing
+	if author.UserID == viewer.ID {
+		return event.Type == events.TypeTyping, nil
+	}
+
+	isBlocked, ok := blocked[author.UserID]
+	if !ok {
+		var err error
+		isBlocked, err = app.store.Blocks.IsBlocked(ctx, viewer.ID, author.UserID)
+		if err != nil {
+			return false, err
+		}
+		blocked[author.UserID] = isBlocked
+	}
+
+	return isBlocked, nil
+}
+
+// joinLive counts a client as connected to a post, or keeps it counted, and
+// tells the other clients how many are connected.
+func (app *application) joinLive(ctx context.Context, postID int64, clientID string) {
+	count, err := app.presence.Join(ctx, events.PostTopic(postID), clientID)
+	if err != nil {
+		app.logger.Errorw("error joining post", "post", postID, "error", err)
+		return
+	}
+
+	app.publishPresence(ctx, postID, count)
+}
+
+func (app *application) leaveLive(postID int64, clientID string) {
+	// the request context is already done
+	ctx, cancel := context.WithTimeout(context.Background(), liveWriteWait)
+	defer cancel()
+
+	count, err := app.presence.Leave(ctx, events.PostTopic(postID), clientID)
+	if err != nil {
+		app.logger.Errorw("error leaving post", "post", postID, "error", err)
+		return
+	}
+
+	app.publishPresence(ctx, postID, count)
+}
+
+func (app *application) publishPresence(ctx context.Context, postID int64, count int64) {
+	presence := events.Event{Type: events.TypePresence, Transient: true}
+	app.publishTo(ctx, events.PostTopic(postID), presence, livePresenceData{Count: count})
+}
+
+// checkOrigin lets through the frontend, same origin requests and clients
+// that don't send an origin, which are not browsers.
+func (app *application) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	frontend := app.config.FrontendURL
+	if f, err := url.Parse(frontend); err == nil && f.Host != "" {
+		frontend = f.Host
+	}
+
+	return u.Host == frontend || u.Host == r.Host
+}
+
+func closeLive(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(liveWriteWait))
+}
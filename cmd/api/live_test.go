@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shanisharrma/gopher-social/cmd/api/config"
+	"github.com/shanisharrma/gopher-social/internal/events"
+)
+
+func TestLivePost(t *testing.T) {
+	app := newTestApplication(t, config.Config{})
+	srv := httptest.NewServer(app.mount())
+	defer srv.Close()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/v1/posts/1/live"
+
+	t.Run("should not allow unauthenticated connections", func(t *testing.T) {
+		_, res, err := websocket.DefaultDialer.Dial(url, nil)
+		if err == nil {
+			t.Fatal("expected the upgrade to fail")
+		}
+
+		checkResponseCode(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("should accept the token as a subprotocol", func(t *testing.T) {
+		dialer := websocket.Dialer{Subprotocols: []string{tokenProtocol, testToken}}
+		conn, _, err := dialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		presence := readLiveEvent(t, conn)
+		if presence.Type != events.TypePresence || string(presence.Data) != `{"count":1}` {
+			t.Errorf("expected a presence count of 1. got %+v", presence)
+		}
+	})
+
+	t.Run("should rate limit messages", func(t *testing.T) {
+		header := http.Header{"Authorization": {"Bearer " + testToken}}
+		conn, _, err := websocket.DefaultDialer.Dial(url, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		for range liveMessageLimit + 1 {
+			if err := conn.WriteJSON(liveMessage{Type: "unknown"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// presence counts of clients coming and going are interleaved
+		var last events.Event
+		for replies := 0; replies < liveMessageLimit+1; {
+			if last = readLiveEvent(t, conn); last.Type != events.TypePresence {
+				replies++
+			}
+		}
+
+		var reply liveErrorData
+		if err := json.Unmarshal(last.Data, &reply); err != nil {
+			t.Fatal(err)
+		}
+
+		if reply.Error != errLiveRateLimited.Error() {
+			t.Errorf("expected the last message to be rate limited. got %q", reply.Error)
+		}
+	})
+}
+
+func readLiveEvent(t *testing.T, conn *websocket.Conn) events.Event {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	var event events.Event
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatal(err)
+	}
+
+	return event
+}
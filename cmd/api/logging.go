@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type accessLogKey string
+
+const accessLogCtx accessLogKey = "access_log"
+
+// accessLogEntry collects what the access log learns from inner handlers,
+// such as the authenticated user, which isn't known when the request starts.
+type accessLogEntry struct {
+	userID atomic.Int64
+}
+
+// loggedHeaders are the request headers written to the access log.
+var loggedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Sec-Websocket-Protocol",
+	"User-Agent",
+	"Referer",
+	"Content-Type",
+	"Content-Length",
+}
+
+// redactedHeaders carry credentials, so only their presence is logged. The
+// WebSocket subprotocol carries the token of browser clients.
+var redactedHeaders = map[string]bool{
+	"Authorization":          true,
+	"Proxy-Authorization":    true,
+	"Cookie":                 true,
+	"Sec-Websocket-Protocol": true,
+}
+
+// accessLogMiddleware logs every failed request and a sample of the
+// successful ones, with their route pattern, status and latency.
+func (app *application) accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry := &accessLogEntry{}
+		r = r.WithContext(context.WithValue(r.Context(), accessLogCtx, entry))
+
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		if status < http.StatusBadRequest && rand.Float64() >= app.config.AccessLog.SampleRatio {
+			return
+		}
+
+		fields := []any{
+			"request_id", middleware.GetReqID(r.Context()),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", chi.RouteContext(r.Context()).RoutePattern(),
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"latency", time.Since(start),
+			"ip", clientIP(r),
+			"headers", logHeaders(r.Header),
+		}
+		if userID := entry.userID.Load(); userID != 0 {
+			fields = append(fields, "user_id", userID)
+		}
+
+		logger := app.requestLogger(r)
+		switch {
+		case status >= http.StatusInternalServerError:
+			logger.Errorw("request", fields...)
+		case status >= http.StatusBadRequest:
+			logger.Warnw("request", fields...)
+		default:
+			logger.Infow("request", fields...)
+		}
+	})
+}
+
+// setAccessLogUser records the authenticated user of a request for the
+// access log.
+func setAccessLogUser(ctx context.Context, userID int64) {
+	if entry, ok := ctx.Value(accessLogCtx).(*accessLogEntry); ok {
+		entry.userID.Store(userID)
+	}
+}
+
+func logHeaders(header http.Header) map[string]string {
+	logged := make(map[string]string, len(loggedHeaders))
+	for _, name := range loggedHeaders {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		if redactedHeaders[name] {
+			logged[name] = "[REDACTED]"
+			continue
+		}
+		logged[name] = strings.Join(values, ", ")
+	}
+
+	return logged
+}
@@ -1,18 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"expvar"
+	"flag"
 	"log"
+	"os"
 	"runtime"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/shanisharrma/gopher-social/cmd/api/config"
 	"github.com/shanisharrma/gopher-social/internal/auth"
 	"github.com/shanisharrma/gopher-social/internal/db"
+	"github.com/shanisharrma/gopher-social/internal/events"
+	"github.com/shanisharrma/gopher-social/internal/features"
+	"github.com/shanisharrma/gopher-social/internal/health"
 	"github.com/shanisharrma/gopher-social/internal/mailer"
+	"github.com/shanisharrma/gopher-social/internal/metrics"
+	"github.com/shanisharrma/gopher-social/internal/notifications"
 	"github.com/shanisharrma/gopher-social/internal/ratelimiter"
 	"github.com/shanisharrma/gopher-social/internal/store"
 	"github.com/shanisharrma/gopher-social/internal/store/cache"
+	"github.com/shanisharrma/gopher-social/internal/tracing"
+	"github.com/shanisharrma/gopher-social/internal/trending"
 	"go.uber.org/zap"
 )
 
@@ -36,15 +47,37 @@ const version = "0.0.1"
 // @name						Authorization
 // @description
 func main() {
-	cfg, err := config.MustLoad()
+	cfg, opts, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if opts.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err.Error())
+		}
+	}
 	if err != nil {
 		log.Fatal(err.Error())
 	}
+	if opts.PrintConfig {
+		return
+	}
 
 	// Logger
 	logger := zap.Must(zap.NewProduction()).Sugar()
 	defer logger.Sync()
 
+	// Tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, version)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Errorw("error flushing traces", "error", err)
+		}
+	}()
+
 	// configuring Database connection
 	db, err := db.New(cfg.Db.Addr, cfg.Db.MaxOpenConns, cfg.Db.MaxIdleConns, cfg.Db.MaxIdleTime)
 	if err != nil {
@@ -60,8 +93,33 @@ func main() {
 		logger.Info("redis cache connection established")
 	}
 
-	// Rate Limiter
-	ratelimiter := ratelimiter.NewFixedWindowRateLimiter(cfg.Ratelimiter.RequestPerTimeFrame, cfg.Ratelimiter.TimeFrame)
+	// Events, shared between instances through redis when it is available
+	var broker events.Broker = events.NewMemoryBroker()
+	var presence events.Presence = events.NewMemoryPresence()
+	if cfg.RedisCfg.Enabled {
+		broker = events.NewRedisBroker(rdb)
+		presence = events.NewRedisPresence(rdb)
+	}
+	defer broker.Close()
+
+	// Trending, computed by one instance when they share redis
+	var trendingResults trending.Results = trending.NewMemoryResults()
+	if cfg.RedisCfg.Enabled {
+		trendingResults = trending.NewRedisResults(rdb)
+	}
+
+	// Rate Limiter, shared between instances through redis when it is available
+	newLimiter := ratelimiter.New
+	if cfg.RedisCfg.Enabled {
+		newLimiter = func(cfg ratelimiter.Config) (ratelimiter.Limiter, error) {
+			return ratelimiter.NewRedisRateLimiter(rdb, cfg, logger)
+		}
+	}
+	ratelimiters, err := ratelimiter.NewPolicies(cfg.Ratelimiter, newLimiter)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer ratelimiters.Stop()
 
 	// configuring database store (relational and cache)
 	store := store.NewStorage(db)
@@ -74,6 +132,28 @@ func main() {
 		logger.Fatal(err)
 	}
 
+	// Background jobs, stopped once the server has shut down
+	jobs := newJobs()
+
+	// Feature flags, reloaded when an instance announces a change
+	flags := features.New(store.FeatureFlags, broker, cfg.Features.RefreshInterval, logger)
+	if err := flags.Refresh(context.Background()); err != nil {
+		logger.Errorw("error loading feature flags, using the defaults", "error", err)
+	}
+	jobs.Go(flags.Run)
+
+	// Readiness checks of the dependencies
+	checker := health.NewChecker(cfg.Health.CacheTTL)
+	checker.Register("postgres", cfg.Health.CheckTimeout, db.PingContext)
+	if cfg.RedisCfg.Enabled {
+		checker.Register("redis", cfg.Health.CheckTimeout, func(ctx context.Context) error {
+			return rdb.Ping(ctx).Err()
+		})
+	}
+	if cfg.Health.CheckMailer {
+		checker.Register("mailer", cfg.Health.CheckTimeout, mailtrap.Ping)
+	}
+
 	// JWT Authenticator
 	jwtAuthenticator := auth.NewJWTAuthenticator(cfg.Auth.Token.Secret, cfg.Auth.Token.Iss, cfg.Auth.Token.Iss)
 
@@ -85,10 +165,23 @@ func main() {
 		logger:        logger,
 		mailer:        mailtrap,
 		authenticator: jwtAuthenticator,
-		ratelimiter:   ratelimiter,
+		ratelimiters:  ratelimiters,
+		notifications: notifications.NewPostgresService(db),
+		events:        broker,
+		presence:      presence,
+		trending:      trendingResults,
+		health:        checker,
+		features:      flags,
+		jobs:          jobs,
+	}
+
+	if cfg.Trending.Enabled {
+		job := trending.NewJob(store.Trending, trendingResults, cfg.Trending.Interval, logger)
+		jobs.Go(job.Run)
 	}
 
 	// metrics collected
+	metrics.RegisterDB(db)
 	expvar.NewString("version").Set(version)
 	expvar.Publish("database", expvar.Func(func() any {
 		return db.Stats()
@@ -98,5 +191,7 @@ func main() {
 	}))
 
 	mux := app.mount()
-	logger.Fatal(app.run(mux))
+	if err := app.run(mux); err != nil {
+		logger.Fatal(err)
+	}
 }
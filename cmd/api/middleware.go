@@ -4,41 +4,27 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"github.com/shanisharrma/gopher-social/internal/audit"
+	"github.com/shanisharrma/gopher-social/internal/features"
+	"github.com/shanisharrma/gopher-social/internal/metrics"
+	"github.com/shanisharrma/gopher-social/internal/ratelimiter"
 	"github.com/shanisharrma/gopher-social/internal/store"
 )
 
 func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// read the token
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			app.unauthorizedErrorResponse(w, r, fmt.Errorf("auth header missing"))
-			return
-		}
-
-		// parse it -> get the beared token
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			app.unauthorizedErrorResponse(w, r, fmt.Errorf("auth header is malformed"))
-			return
-		}
-
-		token := parts[1]
-
-		// decode it
-		jwtToken, err := app.authenticator.ValidateToken(token)
-		if err != nil {
-			app.unauthorizedErrorResponse(w, r, err)
-			return
-		}
-
-		claims, _ := jwtToken.Claims.(jwt.MapClaims)
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+		userID, err := app.tokenUserID(r)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
@@ -53,12 +39,69 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
 		}
+
+		// tokens issued before a suspension stop working while it lasts
+		if app.rejectSuspended(w, r, user) {
+			return
+		}
 		// set in the request context
 		ctx = context.WithValue(ctx, userCtx, user)
+		setAccessLogUser(ctx, user.ID)
+		ctx = audit.WithActor(ctx, audit.Actor{
+			UserID:    user.ID,
+			RequestID: middleware.GetReqID(ctx),
+			IP:        clientIP(r),
+		})
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// tokenUserID reads the token of a request and returns the ID of the user it
+// was issued to, without checking that the user still exists.
+func (app *application) tokenUserID(r *http.Request) (int64, error) {
+	// read the token
+	token, err := bearerToken(r)
+	if err != nil {
+		return 0, err
+	}
+
+	// decode it
+	jwtToken, err := app.authenticator.ValidateToken(token)
+	if err != nil {
+		return 0, err
+	}
+
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+	return strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+}
+
+// tokenProtocol is the WebSocket subprotocol carrying the token of browser
+// clients, which can't set headers on the upgrade request. They ask for the
+// protocols "access_token" and the token itself.
+const tokenProtocol = "access_token"
+
+// bearerToken reads the token from the Authorization header or, for WebSocket
+// upgrades, from the requested subprotocols.
+func bearerToken(r *http.Request) (string, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		protocols := websocket.Subprotocols(r)
+		if len(protocols) == 2 && protocols[0] == tokenProtocol {
+			return protocols[1], nil
+		}
+
+		return "", fmt.Errorf("auth header missing")
+	}
+
+	// parse it -> get the beared token
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return "", fmt.Errorf("auth header is malformed")
+	}
+
+	return parts[1], nil
+}
+
 func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -98,7 +141,10 @@ func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 	}
 }
 
-func (app *application) checkPostOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+// checkPostOwnership lets through the author of the post and users whose
+// role is at least requiredRole. When communityModerated is set, moderators
+// of the community the post was shared in are let through as well.
+func (app *application) checkPostOwnership(requiredRole string, communityModerated bool, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := getUserFromCtx(r)
 		post := getPostFromCtx(r)
@@ -116,6 +162,14 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 			return
 		}
 
+		if !allowed && communityModerated {
+			allowed, err = app.isCommunityModerator(r.Context(), post, user)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+		}
+
 		if !allowed {
 			app.forbiddenResponse(w, r)
 			return
@@ -125,6 +179,27 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 	})
 }
 
+// requireRole only lets through authenticated users whose role is at least
+// as high as roleName. It must run after AuthTokenMiddleware.
+func (app *application) requireRole(roleName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, err := app.checkRolePrecedence(r.Context(), getUserFromCtx(r), roleName)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			if !allowed {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
 	role, err := app.store.Roles.GetByName(ctx, roleName)
 	if err != nil {
@@ -160,15 +235,174 @@ func (app *application) getUser(ctx context.Context, userID int64) (*store.User,
 	return user, nil
 }
 
+// rejectSuspended writes a suspended response and returns true when the user
+// has a suspension in effect. The suspension is loaded and cached with the
+// user, and is checked against the current time, so it lifts on its own.
+func (app *application) rejectSuspended(w http.ResponseWriter, r *http.Request, user *store.User) bool {
+	if user.Suspension == nil || !user.Suspension.InEffect(time.Now()) {
+		return false
+	}
+
+	app.suspendedResponse(w, r, user.Suspension)
+	return true
+}
+
+// realIPMiddleware replaces RemoteAddr by the client address forwarded in the
+// X-Forwarded-For or X-Real-IP headers, only when the request comes from one
+// of the trusted proxies. Anyone else could claim any address, and so escape
+// the limits kept by address.
+func realIPMiddleware(proxies []netip.Prefix) func(http.Handler) http.Handler {
+	trusted := func(addr netip.Addr) bool {
+		for _, proxy := range proxies {
+			if proxy.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer, err := netip.ParseAddrPort(r.RemoteAddr)
+			if err == nil && trusted(peer.Addr()) {
+				if client, ok := forwardedClient(r, trusted); ok {
+					r.RemoteAddr = client.String()
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClient walks X-Forwarded-For back from the closest proxy and
+// returns the first address that isn't a trusted proxy, falling back on
+// X-Real-IP.
+func forwardedClient(r *http.Request, trusted func(netip.Addr) bool) (netip.Addr, bool) {
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	var client netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+
+		client = addr
+		if !trusted(addr) {
+			break
+		}
+	}
+
+	if client.IsValid() {
+		return client.Unmap(), true
+	}
+
+	addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+	return addr.Unmap(), err == nil
+}
+
+// clientIP returns the address of the client without its port. The
+// realIPMiddleware has already replaced RemoteAddr by the forwarded address
+// when it came through a trusted proxy.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// invalidateUser drops the cached copy of a user after it has been changed in
+// the database.
+func (app *application) invalidateUser(ctx context.Context, userID int64) error {
+	if !app.config.RedisCfg.Enabled {
+		return nil
+	}
+
+	return app.cacheStorage.Users.Delete(ctx, userID)
+}
+
+// RateLimiterMiddleware limits every request under the default allowance.
 func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.config.Ratelimiter.Enabled {
-			if allow, retryAfter := app.ratelimiter.Allow(r.RemoteAddr); !allow {
-				app.rateLimitExceededResponse(w, r, retryAfter.String())
+	return app.rateLimit(ratelimiter.PolicyDefault)(next)
+}
+
+// rateLimit limits requests under the named policy and reports the state of
+// the allowance in the RateLimit headers. When several policies apply, the
+// headers are those of the innermost one.
+func (app *application) rateLimit(policy string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limiter, ok := app.ratelimiters[policy]
+			if !app.config.Ratelimiter.Enabled || !ok || !app.featureEnabled(r, features.RateLimiter) {
+				next.ServeHTTP(w, r)
 				return
 			}
+
+			res := limiter.Allow(policy + ":" + app.rateLimitKey(r))
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				metrics.RateLimitRejections.WithLabelValues(policy).Inc()
+				app.rateLimitExceededResponse(w, r, res.RetryAfter)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey identifies who made a request: the user when it carries a
+// valid token, so that users sharing an address don't share an allowance, and
+// the address otherwise.
+func (app *application) rateLimitKey(r *http.Request) string {
+	if user, ok := r.Context().Value(userCtx).(*store.User); ok {
+		return "user:" + strconv.FormatInt(user.ID, 10)
+	}
+
+	if userID, err := app.tokenUserID(r); err == nil {
+		return "user:" + strconv.FormatInt(userID, 10)
+	}
+
+	return "ip:" + clientIP(r)
+}
+
+// ceilSeconds rounds d up to whole seconds.
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// metricsMiddleware records the duration of requests by route pattern and
+// status, which also counts them, and the number of requests in flight.
+func (app *application) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics.RequestsInFlight.Inc()
+		defer metrics.RequestsInFlight.Dec()
+
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			route = "unmatched"
 		}
 
-		next.ServeHTTP(w, r)
+		// nothing was written, as for empty or hijacked responses
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		metrics.RequestDuration.
+			WithLabelValues(r.Method, route, strconv.Itoa(status)).
+			Observe(time.Since(start).Seconds())
 	})
 }
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shanisharrma/gopher-social/cmd/api/config"
+	"github.com/shanisharrma/gopher-social/internal/mailer"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+// GetModerationQueue godoc
+//
+//	@Summary		Fetches the moderation queue
+//	@Description	Fetches reports, oldest first, filtered by status, target type and reported user
+//	@Tags			Moderation
+//	@Produce		json
+//	@Param			status				query		string	false	"Status (open, actioned, dismissed)"
+//	@Param			target_type			query		string	false	"Target type (post, comment, user)"
+//	@Param			reported_user_id	query		int		false	"Reported user ID"
+//	@Param			limit				query		int		false	"Limit"
+//	@Param			offset				query		int		false	"Offset"
+//	@Success		200					{object}	[]store.Report
+//	@Failure		400					{object}	error
+//	@Failure		403					{object}	error
+//	@Failure		500					{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/reports [get]
+func (app *application) getModerationQueueHandler(w http.ResponseWriter, r *http.Request) {
+	rq := store.ReportQuery{
+		Limit:  20,
+		Offset: 0,
+		Status: store.ReportStatusOpen,
+	}
+
+	rq, err := rq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(rq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	reports, err := app.store.Reports.List(r.Context(), rq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Reports fetched", reports); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetReport godoc
+//
+//	@Summary		Fetches a report
+//	@Description	Fetches a report together with the moderation actions taken on it
+//	@Tags			Moderation
+//	@Produce		json
+//	@Param			id	path		int	true	"Report ID"
+//	@Success		200	{object}	store.Report
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/reports/{id} [get]
+func (app *application) getReportHandler(w http.ResponseWriter, r *http.Request) {
+	report := app.reportFromParam(w, r)
+	if report == nil {
+		return
+	}
+
+	actions, err := app.store.Reports.GetActions(r.Context(), report.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	report.Actions = actions
+
+	if err := app.jsonResponse(w, http.StatusOK, "Report fetched", report); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type ModerationActionPayload struct {
+	Action       string `json:"action"        validate:"required,oneof=hide warn suspend dismiss"`
+	Note         string `json:"note"          validate:"max=1000"`
+	SuspendHours int    `json:"suspend_hours" validate:"required_if=Action suspend,gte=0,lte=8760"`
+}
+
+// ModerateReport godoc
+//
+//	@Summary		Acts on a report
+//	@Description	Hides the reported content, warns or suspends its owner, or dismisses the report. The reporter is notified and the action is recorded in the audit trail.
+//	@Tags			Moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Report ID"
+//	@Param			payload	body		ModerationActionPayload	true	"Action payload"
+//	@Success		201		{object}	store.ModerationAction
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/reports/{id}/actions [post]
+func (app *application) moderateReportHandler(w http.ResponseWriter, r *http.Request) {
+	report := app.reportFromParam(w, r)
+	if report == nil {
+		return
+	}
+
+	var payload ModerationActionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Action == store.ModerationActionHide && report.TargetType == store.ReportTargetUser {
+		app.badRequestResponse(w, r, errors.New("only posts and comments can be hidden"))
+		return
+	}
+
+	ctx := r.Context()
+
+	// moderators only act on users below their own role, never on themselves
+	moderator := getUserFromCtx(r)
+	target, err := app.getUser(ctx, report.ReportedUserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if target.ID == moderator.ID || target.Role.Level >= moderator.Role.Level {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	action := &store.ModerationAction{
+		ModeratorID:  moderator.ID,
+		Action:       payload.Action,
+		TargetUserID: report.ReportedUserID,
+		Note:         payload.Note,
+	}
+
+	if payload.Action == store.ModerationActionSuspend {
+		until := time.Now().Add(time.Duration(payload.SuspendHours) * time.Hour)
+		action.SuspendUntil = &until
+	}
+
+	if err := app.store.Reports.Resolve(ctx, report, action); err != nil {
+		switch err {
+		case store.ErrReportResolved:
+			app.conflictResponse(w, r, err)
+		case store.ErrOutranked:
+			app.forbiddenResponse(w, r)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if action.Action == store.ModerationActionSuspend {
+		if err := app.invalidateUser(ctx, action.TargetUserID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	app.notifyModerationOutcome(ctx, report, action)
+
+	if err := app.jsonResponse(w, http.StatusCreated, "Report resolved", action); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// notifyModerationOutcome emails the reporter about the decision and, for
+// warnings and suspensions, the reported user. Failures are only logged, the
+// action itself has already been committed.
+func (app *application) notifyModerationOutcome(ctx context.Context, report *store.Report, action *store.ModerationAction) {
+	reporter, err := app.getUser(ctx, report.ReporterID)
+	if err == nil {
+		vars := struct {
+			Username   string
+			TargetType string
+			Status     string
+		}{
+			Username:   reporter.Username,
+			TargetType: report.TargetType,
+			Status:     report.Status,
+		}
+		err = app.sendEmail(ctx, mailer.ReportResolvedTemplate, reporter, vars)
+	}
+	if err != nil {
+		app.logger.Errorw("error notifying reporter", "report", report.ID, "error", err)
+	}
+
+	if action.Action != store.ModerationActionWarn && action.Action != store.ModerationActionSuspend {
+		return
+	}
+
+	reported, err := app.getUser(ctx, action.TargetUserID)
+	if err == nil {
+		vars := struct {
+			Username     string
+			Note         string
+			SuspendUntil string
+		}{
+			Username: reported.Username,
+			Note:     action.Note,
+		}
+		if action.SuspendUntil != nil {
+			vars.SuspendUntil = action.SuspendUntil.Format(time.RFC1123)
+		}
+		err = app.sendEmail(ctx, mailer.UserWarningTemplate, reported, vars)
+	}
+	if err != nil {
+		app.logger.Errorw("error notifying reported user", "report", report.ID, "error", err)
+	}
+}
+
+func (app *application) sendEmail(ctx context.Context, templateFile string, user *store.User, vars any) error {
+	isProdEnv := app.config.Env == config.EnvProduction
+
+	status, err := app.mailer.Send(ctx, templateFile, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Infow("Email sent", "template", templateFile, "status code", status)
+	return nil
+}
+
+// reportFromParam loads the report named by the {reportID} url param. It
+// writes the error response itself and returns nil when the request should
+// stop.
+func (app *application) reportFromParam(w http.ResponseWriter, r *http.Request) *store.Report {
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil
+	}
+
+	report, err := app.store.Reports.GetById(r.Context(), reportID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil
+	}
+
+	return report
+}
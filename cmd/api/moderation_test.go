@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/shanisharrma/gopher-social/cmd/api/config"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+// rankedUserStore gives each user the role level in levels.
+type rankedUserStore struct {
+	store.MockUserStore
+	levels map[int64]int
+}
+
+func (s rankedUserStore) GetById(ctx context.Context, userID int64) (*store.User, error) {
+	return &store.User{ID: userID, Role: store.Role{Level: s.levels[userID]}}, nil
+}
+
+// selfReportStore reports the test token's own user.
+type selfReportStore struct {
+	store.MockReportStore
+}
+
+func (s selfReportStore) GetById(ctx context.Context, reportID int64) (*store.Report, error) {
+	return &store.Report{ID: reportID, TargetType: store.ReportTargetUser, ReportedUserID: 42, Status: store.ReportStatusOpen}, nil
+}
+
+func TestModerationQueue(t *testing.T) {
+	app := newTestApplication(t, config.Config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not allow unauthenticated requests", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/moderation/reports", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should not allow users below moderator", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/moderation/reports", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+}
+
+func TestModerateReport(t *testing.T) {
+	app := newTestApplication(t, config.Config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moderate := func() int {
+		req, err := http.NewRequest(http.MethodPost, "/v1/moderation/reports/1/actions", strings.NewReader(`{"action": "warn"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should act on users below the moderator", func(t *testing.T) {
+		app.store.Users = rankedUserStore{levels: map[int64]int{42: 2, 1: 1}}
+
+		checkResponseCode(t, http.StatusCreated, moderate())
+	})
+
+	t.Run("should not act on other moderators", func(t *testing.T) {
+		app.store.Users = rankedUserStore{levels: map[int64]int{42: 2, 1: 2}}
+
+		checkResponseCode(t, http.StatusForbidden, moderate())
+	})
+
+	t.Run("should not act on admins", func(t *testing.T) {
+		app.store.Users = rankedUserStore{levels: map[int64]int{42: 2, 1: 3}}
+
+		checkResponseCode(t, http.StatusForbidden, moderate())
+	})
+
+	t.Run("should not act on the moderator themself", func(t *testing.T) {
+		app.store.Users = rankedUserStore{levels: map[int64]int{42: 3}}
+		app.store.Reports = selfReportStore{}
+
+		checkResponseCode(t, http.StatusForbidden, moderate())
+	})
+}
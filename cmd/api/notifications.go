@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/shanisharrma/gopher-social/internal/events"
+	"github.com/shanisharrma/gopher-social/internal/notifications"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+// notify records a notification and pushes it to the recipient's live
+// streams. It never fails the request that caused it, errors are only logged.
+func (app *application) notify(ctx context.Context, event notifications.Event) {
+	created, err := app.notifications.Notify(ctx, event)
+	if err != nil {
+		app.logger.Errorw("error sending notification", "type", event.Type, "recipient", event.RecipientID, "error", err)
+		return
+	}
+
+	if created {
+		app.publish(ctx, event.RecipientID, events.TypeNotification, event)
+	}
+}
+
+// notifyMentions lets the users mentioned by actorID in a post, or in a
+// comment on it, know about it. Users who can't see the post aren't told.
+func (app *application) notifyMentions(ctx context.Context, actorID int64, post *store.Post, mentionedIDs []int64) {
+	for _, userID := range mentionedIDs {
+		user, err := app.getUser(ctx, userID)
+		if err != nil {
+			app.logger.Errorw("error fetching mentioned user", "user", userID, "error", err)
+			continue
+		}
+
+		allowed, err := app.canViewPost(ctx, user, post)
+		if err != nil {
+			app.logger.Errorw("error checking post visibility", "user", userID, "post", post.ID, "error", err)
+			continue
+		}
+		if !allowed {
+			continue
+		}
+
+		app.notify(ctx, notifications.Event{
+			Type:        notifications.TypeMention,
+			RecipientID: userID,
+			ActorID:     actorID,
+			EntityType:  "post",
+			EntityID:    post.ID,
+		})
+	}
+}
+
+// GetNotifications godoc
+//
+//	@Summary		Fetches notifications
+//	@Description	Fetches the authenticated user's notifications, grouped by type and subject until read, most recent first
+//	@Tags			Notifications
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]notifications.Notification
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications [get]
+func (app *application) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset := 20, 0
+	qs := r.URL.Query()
+
+	if l := qs.Get("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil || v < 1 || v > 50 {
+			app.badRequestResponse(w, r, errors.New("limit must be between 1 and 50"))
+			return
+		}
+		limit = v
+	}
+
+	if o := qs.Get("offset"); o != "" {
+		v, err := strconv.Atoi(o)
+		if err != nil || v < 0 {
+			app.badRequestResponse(w, r, errors.New("offset must be a positive number"))
+			return
+		}
+		offset = v
+	}
+
+	user := getUserFromCtx(r)
+
+	list, err := app.notifications.List(r.Context(), user.ID, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Notifications fetched", list); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetUnreadNotificationsCount godoc
+//
+//	@Summary		Counts unread notifications
+//	@Description	Counts the authenticated user's unread notifications
+//	@Tags			Notifications
+//	@Produce		json
+//	@Success		200	{object}	map[string]int
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/unread-count [get]
+func (app *application) getUnreadNotificationsCountHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	count, err := app.notifications.UnreadCount(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Unread notifications counted", map[string]int{"unread": count}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type MarkNotificationsReadPayload struct {
+	IDs []int64 `json:"ids" validate:"required,min=1,max=100"`
+}
+
+// MarkNotificationsRead godoc
+//
+//	@Summary		Marks notifications as read
+//	@Description	Marks the given notifications, and the rest of their group, as read
+//	@Tags			Notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MarkNotificationsReadPayload	true	"Notification IDs"
+//	@Success		204		{string}	string							"Notifications read"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/read [put]
+func (app *application) markNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MarkNotificationsReadPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := app.notifications.MarkRead(r.Context(), user.ID, payload.IDs); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Notifications read", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// MarkAllNotificationsRead godoc
+//
+//	@Summary		Marks all notifications as read
+//	@Description	Marks all the authenticated user's notifications as read
+//	@Tags			Notifications
+//	@Produce		json
+//	@Success		204	{string}	string	"Notifications read"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/read-all [put]
+func (app *application) markAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if err := app.notifications.MarkAllRead(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Notifications read", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetNotificationPreferences godoc
+//
+//	@Summary		Fetches notification preferences
+//	@Description	Fetches which notification types the authenticated user receives
+//	@Tags			Notifications
+//	@Produce		json
+//	@Success		200	{object}	[]notifications.Preference
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/preferences [get]
+func (app *application) getNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	preferences, err := app.notifications.GetPreferences(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Preferences fetched", preferences); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type UpdateNotificationPreferencePayload struct {
+	Type    notifications.Type `json:"type"    validate:"required"`
+	Enabled *bool              `json:"enabled" validate:"required"`
+}
+
+// UpdateNotificationPreference godoc
+//
+//	@Summary		Updates a notification preference
+//	@Description	Turns a notification type on or off for the authenticated user
+//	@Tags			Notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateNotificationPreferencePayload	true	"Preference payload"
+//	@Success		204		{string}	string								"Preference updated"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/preferences [put]
+func (app *application) updateNotificationPreferenceHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateNotificationPreferencePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !payload.Type.Valid() {
+		app.badRequestResponse(w, r, errors.New("unknown notification type"))
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := app.notifications.SetPreference(r.Context(), user.ID, payload.Type, *payload.Enabled); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Preference updated", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
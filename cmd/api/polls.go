@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+// maxPollDuration is how far in the future polls may close.
+const maxPollDuration = 30 * 24 * time.Hour
+
+type CreatePollPayload struct {
+	Options        []string  `json:"options"         validate:"required,min=2,max=6,unique,dive,required,max=100"`
+	MultipleChoice bool      `json:"multiple_choice"`
+	ClosesAt       time.Time `json:"closes_at"       validate:"required"`
+}
+
+type VotePayload struct {
+	OptionIDs []int64 `json:"option_ids" validate:"required,min=1,max=6,unique,dive,gt=0"`
+}
+
+// newPoll checks the closing time of a poll payload and turns it into a poll.
+func newPoll(payload *CreatePollPayload) (*store.Poll, error) {
+	now := time.Now()
+	if !payload.ClosesAt.After(now) {
+		return nil, errors.New("poll must close in the future")
+	}
+
+	if payload.ClosesAt.After(now.Add(maxPollDuration)) {
+		return nil, errors.New("poll must close within 30 days")
+	}
+
+	options := make([]store.PollOption, len(payload.Options))
+	for i, text := range payload.Options {
+		options[i] = store.PollOption{Text: text}
+	}
+
+	return &store.Poll{
+		MultipleChoice: payload.MultipleChoice,
+		ClosesAt:       payload.ClosesAt,
+		Options:        options,
+	}, nil
+}
+
+// VotePoll godoc
+//
+//	@Summary		Votes on a poll
+//	@Description	Votes on the poll of a post, for a single option unless the poll is multiple choice. Users vote once and the results are returned.
+//	@Tags			Posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int			true	"Post ID"
+//	@Param			payload	body		VotePayload	true	"Chosen options"
+//	@Success		201		{object}	store.Poll
+//	@Failure		400		{object}	error	"Invalid choice or poll closed"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Already voted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/poll/votes [post]
+func (app *application) votePollHandler(w http.ResponseWriter, r *http.Request) {
+	var payload VotePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	allowed, err := app.canViewPost(ctx, user, post)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	polls, err := app.store.Polls.GetForPosts(ctx, []int64{post.ID}, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	poll, ok := polls[post.ID]
+	if !ok {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	if err := app.store.Polls.Vote(ctx, poll.ID, user.ID, payload.OptionIDs); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, err)
+		case store.ErrPollClosed, store.ErrInvalidChoice:
+			app.badRequestResponse(w, r, err)
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// fetched again for the results, which the vote revealed
+	polls, err = app.store.Polls.GetForPosts(ctx, []int64{post.ID}, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, "Vote recorded", polls[post.ID]); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
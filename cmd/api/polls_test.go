@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/cmd/api/config"
+)
+
+func TestCreatePostWithPoll(t *testing.T) {
+	app := newTestApplication(t, config.Config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closesAt := time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name string
+		poll string
+		code int
+	}{
+		{"should create a post with a poll", `{"options": ["a", "b"], "closes_at": "` + closesAt + `"}`, http.StatusCreated},
+		{"should need two options", `{"options": ["a"], "closes_at": "` + closesAt + `"}`, http.StatusBadRequest},
+		{"should not allow duplicate options", `{"options": ["a", "a"], "closes_at": "` + closesAt + `"}`, http.StatusBadRequest},
+		{"should close in the future", `{"options": ["a", "b"], "closes_at": "2020-01-01T00:00:00Z"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"title": "poll", "content": "which one?", "poll": ` + tt.poll + `}`
+			req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+
+			rr := executeRequest(req, mux)
+
+			checkResponseCode(t, tt.code, rr.Code)
+		})
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/shanisharrma/gopher-social/internal/events"
+	"github.com/shanisharrma/gopher-social/internal/metrics"
 	"github.com/shanisharrma/gopher-social/internal/store"
 )
 
@@ -16,9 +19,11 @@ type postKey string
 var postCtx postKey = "post"
 
 type CreatePostPayload struct {
-	Title   string   `json:"title"   validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title       string             `json:"title"        validate:"required,max=100"`
+	Content     string             `json:"content"      validate:"required,max=1000"`
+	Tags        []string           `json:"tags"`
+	CommunityID *int64             `json:"community_id" validate:"omitempty,gt=0"`
+	Poll        *CreatePollPayload `json:"poll"`
 }
 
 // GetPost
@@ -38,8 +43,21 @@ type CreatePostPayload struct {
 //	@Router			/posts/{id} [get]
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
+	viewer := getUserFromCtx(r)
+	ctx := r.Context()
 
-	comments, err := app.store.Comments.GetByPostId(r.Context(), post.ID)
+	allowed, err := app.canViewPost(ctx, viewer, post)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	comments, err := app.store.Comments.GetByPostId(ctx, post.ID, viewer.ID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -47,6 +65,14 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	post.Comments = comments
 
+	polls, err := app.store.Polls.GetForPosts(ctx, []int64{post.ID}, viewer.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	post.Poll = polls[post.ID]
+
 	if err := app.jsonResponse(w, http.StatusOK, "Post fetched", post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -56,7 +82,7 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 // CreatePost godoc
 //
 //	@Summary		Create a post
-//	@Description	Create a post
+//	@Description	Create a post, shared in a community the user is a member of when community_id is set, with an optional poll of 2 to 6 options
 //	@Tags			Posts
 //	@Accept			json
 //	@Produce		json
@@ -64,6 +90,8 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 //	@Success		201		{object}	store.Post			"Post created"
 //	@Failure		400		{object}	error				"Payload missing"
 //	@failure		401		{object}	error				"Unauthorized"
+//	@Failure		403		{object}	error				"Not a member of the community"
+//	@Failure		404		{object}	error				"Community not found"
 //	@Failure		500		{object}	error				"An error occured"
 //	@Security		ApiKeyAuth
 //	@Router			/posts [post]
@@ -80,20 +108,52 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	if payload.CommunityID != nil {
+		community, err := app.store.Communities.GetById(ctx, *payload.CommunityID, user.ID)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if community.ViewerRole == "" {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
 
 	post := &store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserID:  user.ID,
+		Title:       payload.Title,
+		Content:     payload.Content,
+		AuthorTags:  payload.Tags,
+		UserID:      user.ID,
+		CommunityID: payload.CommunityID,
 	}
 
-	ctx := r.Context()
+	if payload.Poll != nil {
+		poll, err := newPoll(payload.Poll)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		post.Poll = poll
+	}
 
 	if err := app.store.Posts.Create(ctx, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+	metrics.PostsCreated.Inc()
+
+	app.notifyMentions(ctx, user.ID, post, post.MentionedIDs)
+	published := *post
+	app.jobs.Go(func(ctx context.Context) { app.publishNewPost(ctx, published) })
 
 	if err := app.jsonResponse(w, http.StatusCreated, "Post created successfully", post); err != nil {
 		app.internalServerError(w, r, err)
@@ -104,7 +164,7 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 // DeletePost godoc
 //
 //	@Summary		Deletes a post
-//	@Description	Deletes a post using post ID by Authorized (admin, owner)
+//	@Description	Deletes a post using post ID by Authorized (admin, owner, moderators of its community)
 //	@Tags			Posts
 //	@Accept			json
 //	@Produce		json
@@ -144,12 +204,14 @@ type UpdatePostPayload struct {
 	Title   *string   `json:"title"   validate:"omitempty,max=100"`
 	Content *string   `json:"content" validate:"omitempty,max=1000"`
 	Tags    *[]string `json:"tags"    validate:"omitempty"`
+	// IsHidden can only be changed by moderators.
+	IsHidden *bool `json:"is_hidden"`
 }
 
 // UpdatePost
 //
 //	@Summary		Update a post
-//	@Description	Updates a post using post ID by authorized (admin,moderator,owner)
+//	@Description	Updates a post using post ID by authorized (admin,moderator,community moderator,owner). Only moderators can hide or show it.
 //	@Tags			Posts
 //	@Accept			json
 //	@Produce		json
@@ -158,6 +220,7 @@ type UpdatePostPayload struct {
 //	@Success		200		{string}	string				"Post updated"
 //	@Failure		400		{object}	error				"Payload missing"
 //	@Failure		401		{object}	error				"Unauthorized"
+//	@Failure		403		{object}	error				"Forbidden"
 //	@Failure		404		{object}	error				"Not Found"
 //	@Failure		500		{object}	error				"An error occured"
 //	@Security		ApiKeyAuth
@@ -184,7 +247,20 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		post.Title = *payload.Title
 	}
 	if payload.Tags != nil {
-		post.Tags = *payload.Tags
+		post.AuthorTags = *payload.Tags
+	}
+	if payload.IsHidden != nil {
+		allowed, err := app.canModeratePost(r.Context(), getUserFromCtx(r), post)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+		post.IsHidden = *payload.IsHidden
 	}
 
 	err := app.store.Posts.Update(r.Context(), post)
@@ -193,12 +269,159 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// only users mentioned for the first time hear about it
+	app.notifyMentions(r.Context(), post.UserID, post, post.MentionedIDs)
+
 	if err := app.jsonResponse(w, http.StatusOK, "Post updated successfully", nil); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 }
 
+// PinPost godoc
+//
+//	@Summary		Pins a post
+//	@Description	Pins a post to the profile of its author, who can pin up to 3 posts
+//	@Tags			Posts
+//	@Produce		json
+//	@Param			id	path		int		true	"Post ID"
+//	@Success		204	{string}	string	"Post pinned"
+//	@Failure		400	{object}	error	"Too many pinned posts"
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/pin [put]
+func (app *application) pinPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	if post.UserID != getUserFromCtx(r).ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if err := app.store.Posts.Pin(r.Context(), post.ID); err != nil {
+		switch err {
+		case store.ErrPinLimit:
+			app.badRequestResponse(w, r, fmt.Errorf("at most %d posts can be pinned", store.MaxPinnedPosts))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Post pinned", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UnpinPost godoc
+//
+//	@Summary		Unpins a post
+//	@Description	Takes a post off the profile of its author
+//	@Tags			Posts
+//	@Produce		json
+//	@Param			id	path		int		true	"Post ID"
+//	@Success		204	{string}	string	"Post unpinned"
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/unpin [put]
+func (app *application) unpinPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	if post.UserID != getUserFromCtx(r).ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if err := app.store.Posts.Unpin(r.Context(), post.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Post unpinned", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// canViewPost reports whether viewer may see post. Posts of private accounts
+// are hidden from non followers, posts of invite only communities from non
+// members, and hidden posts stay visible to their author and to moderators
+// only.
+func (app *application) canViewPost(ctx context.Context, viewer *store.User, post *store.Post) (bool, error) {
+	author, err := app.getUser(ctx, post.UserID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	allowed, err := app.canViewContent(ctx, viewer, author)
+	if err != nil || !allowed {
+		return false, err
+	}
+
+	if post.CommunityID != nil {
+		community, err := app.store.Communities.GetById(ctx, *post.CommunityID, viewer.ID)
+		if err != nil {
+			return false, err
+		}
+
+		allowed, err := app.canViewCommunity(ctx, viewer, community)
+		if err != nil || !allowed {
+			return false, err
+		}
+	}
+
+	if post.IsHidden && viewer.ID != post.UserID {
+		return app.checkRolePrecedence(ctx, viewer, "moderator")
+	}
+
+	return true, nil
+}
+
+// publishNewPost pushes a new post to the live streams of the author's
+// followers, leaving out those who can't see its community. It runs after the
+// response, as a background job given at most a minute.
+func (app *application) publishNewPost(ctx context.Context, post store.Post) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	followerIDs, err := app.store.Followers.GetFollowerIDs(ctx, post.UserID)
+	if err != nil {
+		app.logger.Errorw("error fetching followers", "user", post.UserID, "error", err)
+		return
+	}
+
+	var community *store.Community
+	if post.CommunityID != nil {
+		community, err = app.store.Communities.GetById(ctx, *post.CommunityID, post.UserID)
+		if err != nil {
+			app.logger.Errorw("error fetching community", "community", *post.CommunityID, "error", err)
+			return
+		}
+	}
+
+	for _, followerID := range followerIDs {
+		if community != nil && community.Visibility != store.CommunityPublic {
+			role, err := app.store.Communities.GetRole(ctx, community.ID, followerID)
+			if err != nil {
+				app.logger.Errorw("error fetching community role", "community", community.ID, "error", err)
+				return
+			}
+
+			if role == "" {
+				continue
+			}
+		}
+
+		app.publish(ctx, followerID, events.TypeFeedPost, post)
+	}
+}
+
 func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		idParam := chi.URLParam(r, "postID")
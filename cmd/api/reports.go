@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+type CreateReportPayload struct {
+	TargetType string `json:"target_type" validate:"required,oneof=post comment user"`
+	TargetID   int64  `json:"target_id"   validate:"required,gt=0"`
+	Reason     string `json:"reason"      validate:"required,max=1000"`
+}
+
+// CreateReport godoc
+//
+//	@Summary		Report content
+//	@Description	Flags a post, comment or user for the moderators
+//	@Tags			Reports
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateReportPayload	true	"Report payload"
+//	@Success		201		{object}	store.Report		"Report created"
+//	@Failure		400		{object}	error				"Payload missing"
+//	@Failure		401		{object}	error				"Unauthorized"
+//	@Failure		404		{object}	error				"Not found"
+//	@Failure		500		{object}	error				"An error occured"
+//	@Security		ApiKeyAuth
+//	@Router			/reports [post]
+func (app *application) createReportHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateReportPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	// resolve the owner of the reported content, it is the one moderators act on
+	var reportedUserID int64
+	var err error
+	switch payload.TargetType {
+	case store.ReportTargetPost:
+		var post *store.Post
+		post, err = app.store.Posts.GetById(ctx, payload.TargetID)
+		if err == nil {
+			reportedUserID = post.UserID
+		}
+	case store.ReportTargetComment:
+		var comment *store.Comment
+		comment, err = app.store.Comments.GetById(ctx, payload.TargetID)
+		if err == nil {
+			reportedUserID = comment.UserID
+		}
+	case store.ReportTargetUser:
+		var reported *store.User
+		reported, err = app.getUser(ctx, payload.TargetID)
+		if err == nil {
+			reportedUserID = reported.ID
+		}
+	}
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if reportedUserID == user.ID {
+		app.badRequestResponse(w, r, errors.New("you cannot report yourself"))
+		return
+	}
+
+	report := &store.Report{
+		ReporterID:     user.ID,
+		TargetType:     payload.TargetType,
+		TargetID:       payload.TargetID,
+		ReportedUserID: reportedUserID,
+		Reason:         payload.Reason,
+	}
+
+	if err := app.store.Reports.Create(ctx, report); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, "Report created", report); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/events"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+// publish pushes an event to a user's live streams. Streams are best effort,
+// errors are only logged.
+func (app *application) publish(ctx context.Context, userID int64, eventType string, data any) {
+	app.publishTo(ctx, events.UserTopic(userID), events.Event{Type: eventType}, data)
+}
+
+// publishToPost pushes an event to the clients following a post live.
+func (app *application) publishToPost(ctx context.Context, postID int64, eventType string, data any) {
+	app.publishTo(ctx, events.PostTopic(postID), events.Event{Type: eventType}, data)
+}
+
+func (app *application) publishTo(ctx context.Context, topic string, event events.Event, data any) {
+	raw, err := json.Marshal(data)
+	if err == nil {
+		event.Data = raw
+		err = app.events.Publish(ctx, topic, event)
+	}
+
+	if err != nil {
+		app.logger.Errorw("error publishing event", "type", event.Type, "topic", topic, "error", err)
+	}
+}
+
+// Stream godoc
+//
+//	@Summary		Streams live updates
+//	@Description	Server-Sent Events stream of new feed posts, notifications and comments for the authenticated user. Send Last-Event-ID to resume after a disconnect.
+//	@Tags			Feed
+//	@Produce		text/event-stream
+//	@Param			Last-Event-ID	header		string	false	"ID of the last event received"
+//	@Success		200				{string}	string	"Event stream"
+//	@Failure		401				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stream [get]
+func (app *application) streamHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+	topic := events.UserTopic(user.ID)
+
+	// the stream outlives the server write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// subscribe before replaying so nothing published in between is lost
+	sub, err := app.events.Subscribe(ctx, topic)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	defer sub.Close()
+
+	replayed := map[string]bool{}
+	var missed []events.Event
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		missed, err = app.events.Replay(ctx, topic, lastEventID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range missed {
+		replayed[event.ID] = true
+		writeEvent(w, event)
+	}
+
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-app.shutdown:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case event, ok := <-sub.C:
+			if !ok {
+				// dropped by the broker, the client reconnects and resumes
+				return
+			}
+			if replayed[event.ID] {
+				continue
+			}
+			writeEvent(w, event)
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event events.Event) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
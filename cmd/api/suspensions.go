@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+type CreateSuspensionPayload struct {
+	Reason   string     `json:"reason"    validate:"required,max=1000"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   time.Time  `json:"ends_at"   validate:"required"`
+}
+
+// CreateSuspension godoc
+//
+//	@Summary		Suspends a user
+//	@Description	Suspends a user for a period of time. The suspension lifts on its own once it ends.
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"User ID"
+//	@Param			payload	body		CreateSuspensionPayload	true	"Suspension payload"
+//	@Success		201		{object}	store.Suspension
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{id}/suspensions [post]
+func (app *application) createSuspensionHandler(w http.ResponseWriter, r *http.Request) {
+	target := app.targetUserFromParam(w, r)
+	if target == nil {
+		return
+	}
+
+	var payload CreateSuspensionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	startsAt := time.Now()
+	if payload.StartsAt != nil {
+		startsAt = *payload.StartsAt
+	}
+
+	if !payload.EndsAt.After(startsAt) || !payload.EndsAt.After(time.Now()) {
+		app.badRequestResponse(w, r, errors.New("ends_at must be in the future and after starts_at"))
+		return
+	}
+
+	admin := getUserFromCtx(r)
+	suspension := &store.Suspension{
+		UserID:   target.ID,
+		Reason:   payload.Reason,
+		IssuedBy: &admin.ID,
+		StartsAt: startsAt,
+		EndsAt:   payload.EndsAt,
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Suspensions.Create(ctx, suspension); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.invalidateUser(ctx, target.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, "User suspended", suspension); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetSuspensions godoc
+//
+//	@Summary		Fetches a user's suspension history
+//	@Description	Fetches every suspension issued to a user, most recent first
+//	@Tags			Admin
+//	@Produce		json
+//	@Param			id	path		int	true	"User ID"
+//	@Success		200	{object}	[]store.Suspension
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{id}/suspensions [get]
+func (app *application) getSuspensionsHandler(w http.ResponseWriter, r *http.Request) {
+	target := app.targetUserFromParam(w, r)
+	if target == nil {
+		return
+	}
+
+	suspensions, err := app.store.Suspensions.GetByUser(r.Context(), target.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Suspensions fetched", suspensions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/cmd/api/config"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+type suspendedUserStore struct {
+	store.MockUserStore
+	endsAt time.Time
+}
+
+func (s suspendedUserStore) GetById(ctx context.Context, userID int64) (*store.User, error) {
+	suspension := &store.Suspension{UserID: userID, Reason: "spam", StartsAt: s.endsAt.Add(-time.Hour), EndsAt: s.endsAt}
+	return &store.User{ID: userID, Suspension: suspension}, nil
+}
+
+func TestSuspendedUser(t *testing.T) {
+	app := newTestApplication(t, config.Config{})
+	endsAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	app.store.Users = suspendedUserStore{endsAt: endsAt}
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should reject tokens of suspended users", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+
+		var body struct {
+			SuspendedUntil time.Time `json:"suspended_until"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		if !body.SuspendedUntil.Equal(endsAt) {
+			t.Errorf("expected suspended_until %v. got %v", endsAt, body.SuspendedUntil)
+		}
+	})
+
+	t.Run("should lift suspensions once they end", func(t *testing.T) {
+		app.store.Users = suspendedUserStore{endsAt: time.Now().Add(-time.Minute)}
+		mux := app.mount()
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+}
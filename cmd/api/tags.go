@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shanisharrma/gopher-social/internal/entities"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+// GetTagPosts godoc
+//
+//	@Summary		Fetches the posts of a hashtag
+//	@Description	Fetches the posts tagged with a hashtag that the user is allowed to see. The tag is matched case insensitively, with or without its leading #.
+//	@Tags			Posts
+//	@Produce		json
+//	@Param			tag		path		string	true	"Hashtag"
+//	@Param			since	query		string	false	"Since"
+//	@Param			until	query		string	false	"Until"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/posts [get]
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag := entities.NormalizeHashtag(chi.URLParam(r, "tag"))
+	if tag == "" {
+		app.badRequestResponse(w, r, errors.New("invalid tag"))
+		return
+	}
+
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	posts, err := app.store.Posts.GetByTag(r.Context(), tag, user.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Posts fetched", posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
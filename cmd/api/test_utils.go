@@ -4,12 +4,19 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/shanisharrma/gopher-social/cmd/api/config"
 	"github.com/shanisharrma/gopher-social/internal/auth"
+	"github.com/shanisharrma/gopher-social/internal/events"
+	"github.com/shanisharrma/gopher-social/internal/features"
+	"github.com/shanisharrma/gopher-social/internal/health"
+	"github.com/shanisharrma/gopher-social/internal/mailer"
+	"github.com/shanisharrma/gopher-social/internal/notifications"
 	"github.com/shanisharrma/gopher-social/internal/ratelimiter"
 	"github.com/shanisharrma/gopher-social/internal/store"
 	"github.com/shanisharrma/gopher-social/internal/store/cache"
+	"github.com/shanisharrma/gopher-social/internal/trending"
 	"go.uber.org/zap"
 )
 
@@ -23,18 +30,29 @@ func newTestApplication(t *testing.T, cfg config.Config) *application {
 
 	testAuth := &auth.TestAuthenticator{}
 
-	ratelimiter := ratelimiter.NewFixedWindowRateLimiter(
-		cfg.Ratelimiter.RequestPerTimeFrame,
-		cfg.Ratelimiter.TimeFrame,
-	)
+	ratelimiters, err := ratelimiter.NewPolicies(cfg.Ratelimiter, ratelimiter.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ratelimiters.Stop)
+
+	broker := events.NewMemoryBroker()
 
 	return &application{
 		config:        cfg,
 		logger:        logger,
 		store:         mockStore,
 		cacheStorage:  mockCacheStorage,
+		mailer:        mailer.NewMockClient(),
 		authenticator: testAuth,
-		ratelimiter:   ratelimiter,
+		ratelimiters:  ratelimiters,
+		notifications: notifications.NewMockService(),
+		events:        broker,
+		presence:      events.NewMemoryPresence(),
+		trending:      trending.NewMemoryResults(),
+		health:        health.NewChecker(0),
+		features:      features.New(mockStore.FeatureFlags, broker, time.Minute, logger),
+		jobs:          newJobs(),
 	}
 }
 
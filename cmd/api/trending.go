@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/shanisharrma/gopher-social/internal/store"
+	"github.com/shanisharrma/gopher-social/internal/trending"
+)
+
+type TrendingPost struct {
+	store.PostWithMetadata
+	Score float64 `json:"score"`
+}
+
+type TrendingResponse struct {
+	Window   string               `json:"window"`
+	Hashtags []store.HashtagScore `json:"hashtags"`
+	Posts    []TrendingPost       `json:"posts"`
+}
+
+// GetTrending godoc
+//
+//	@Summary		Fetches trending hashtags and posts
+//	@Description	Fetches the hashtags and posts with the most activity over a window, recent activity weighing more and posts growing faster than over the previous window ranking higher. Rankings are refreshed every few minutes.
+//	@Tags			Feed
+//	@Produce		json
+//	@Param			window	query		string	false	"Window: 1h, 24h (default) or 7d"
+//	@Param			limit	query		int		false	"Limit"
+//	@Success		200		{object}	TrendingResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/trending [get]
+func (app *application) getTrendingHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	name := "24h"
+	if v := qs.Get("window"); v != "" {
+		name = v
+	}
+
+	window, err := trending.WindowByName(name)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	limit := 10
+	if l := qs.Get("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil || v < 1 || v > 50 {
+			app.badRequestResponse(w, r, errors.New("limit must be between 1 and 50"))
+			return
+		}
+		limit = v
+	}
+
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	hashtags, err := app.trending.Hashtags(ctx, window.Name, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	scores, err := app.trending.Posts(ctx, window.Name, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ids := make([]int64, 0, len(scores))
+	for _, s := range scores {
+		ids = append(ids, s.PostID)
+	}
+
+	// rankings are shared, so what the user can't see is left out here
+	visible, err := app.store.Posts.GetByIds(ctx, ids, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	byID := make(map[int64]store.PostWithMetadata, len(visible))
+	for _, p := range visible {
+		byID[p.ID] = p
+	}
+
+	posts := []TrendingPost{}
+	for _, s := range scores {
+		if p, ok := byID[s.PostID]; ok {
+			posts = append(posts, TrendingPost{PostWithMetadata: p, Score: s.Score})
+		}
+	}
+
+	response := TrendingResponse{
+		Window:   window.Name,
+		Hashtags: hashtags,
+		Posts:    posts,
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Trending fetched", response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/shanisharrma/gopher-social/internal/metrics"
+	"github.com/shanisharrma/gopher-social/internal/notifications"
 	"github.com/shanisharrma/gopher-social/internal/store"
 )
 
@@ -35,7 +38,9 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := app.getUser(r.Context(), userID)
+	ctx := r.Context()
+
+	user, err := app.getUser(ctx, userID)
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
@@ -46,6 +51,36 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	viewer := getUserFromCtx(r)
+
+	// blocked users don't see each other at all
+	blocked, err := app.store.Blocks.IsBlocked(ctx, viewer.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if blocked {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	// the block relationship was checked above
+	allowed, err := app.canViewPrivate(ctx, viewer, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// non followers of a private account only get to know it exists
+	if !allowed {
+		user = &store.User{
+			ID:        user.ID,
+			Username:  user.Username,
+			IsPrivate: user.IsPrivate,
+		}
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, "user fetched", user); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -55,13 +90,16 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 // FollowUser godoc
 //
 //	@Summary		Follow a user
-//	@Description	Follows a user by user ID
+//	@Description	Follows a user by user ID. Following a private account creates a pending follow request instead.
 //	@Tags			Users
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int		true	"User ID"
+//	@Success		202	{string}	string	"Follow request sent"
 //	@Success		204	{string}	string	"User followed"
 //	@Failure		400	{object}	error	"User payload missing"
+//	@Failure		403	{object}	error	"Blocked"
+//	@Failure		404	{object}	error	"Not found"
 //	@Failure		409	{object}	error	"Already followed"
 //	@Failure		500	{object}	error	"an error occured"
 //	@Security		ApiKeyAuth
@@ -69,15 +107,30 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
 	followerUser := getUserFromCtx(r)
 
-	followedUser, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
-	if err != nil {
-		app.badRequestResponse(w, r, err)
+	followedUser := app.targetUserFromParam(w, r)
+	if followedUser == nil {
 		return
 	}
 
 	ctx := r.Context()
 
-	if err := app.store.Followers.Follow(ctx, followedUser, followerUser.ID); err != nil {
+	blocked, err := app.store.Blocks.IsBlocked(ctx, followerUser.ID, followedUser.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if blocked {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if followedUser.IsPrivate {
+		app.requestFollow(w, r, followerUser.ID, followedUser.ID)
+		return
+	}
+
+	if err := app.store.Followers.Follow(ctx, followerUser.ID, followedUser.ID); err != nil {
 		switch err {
 		case store.ErrConflict:
 			app.conflictResponse(w, r, err)
@@ -87,6 +140,15 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 			return
 		}
 	}
+	metrics.Follows.Inc()
+
+	app.notify(ctx, notifications.Event{
+		Type:        notifications.TypeFollow,
+		RecipientID: followedUser.ID,
+		ActorID:     followerUser.ID,
+		EntityType:  "user",
+		EntityID:    followedUser.ID,
+	})
 
 	if err := app.jsonResponse(w, http.StatusNoContent, "user followed", nil); err != nil {
 		app.internalServerError(w, r, err)
@@ -122,6 +184,12 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// unfollowing also withdraws a pending request to a private account
+	if err := app.store.FollowRequests.Delete(ctx, unfollowerUser.ID, unfollowedUser); err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusNoContent, "user followed", nil); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -162,6 +230,167 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+type UpdatePrivacyPayload struct {
+	IsPrivate *bool `json:"is_private" validate:"required"`
+}
+
+// UpdatePrivacy godoc
+//
+//	@Summary		Updates account privacy
+//	@Description	Makes the authenticated user's account private or public
+//	@Tags			Users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdatePrivacyPayload	true	"Privacy payload"
+//	@Success		204		{string}	string					"Privacy updated"
+//	@Failure		400		{object}	error					"Payload missing"
+//	@Failure		500		{object}	error					"An error occured"
+//	@Security		ApiKeyAuth
+//	@Router			/users/privacy [put]
+func (app *application) updatePrivacyHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdatePrivacyPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	if err := app.store.Users.SetPrivacy(ctx, user.ID, *payload.IsPrivate); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.invalidateUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, "Privacy updated", nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetUserPosts godoc
+//
+//	@Summary		Fetches the posts of a user
+//	@Description	Fetches the posts of a user that the authenticated user is allowed to see, newest first. The posts pinned by the user come first on the first page.
+//	@Tags			Users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Param			tags	query		string	false	"Tags"
+//	@Param			since	query		string	false	"Since"
+//	@Param			until	query		string	false	"Until"
+//	@Success		200		{object}	store.PostPage
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/posts [get]
+func (app *application) getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	q, err := store.UserPostsQuery{CursorQuery: store.CursorQuery{Limit: 20}}.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	viewer := getUserFromCtx(r)
+	ctx := r.Context()
+
+	user, err := app.getUser(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	allowed, err := app.canViewContent(ctx, viewer, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	page, err := app.store.Posts.GetByUser(ctx, user.ID, viewer.ID, q)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "Posts fetched", page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// canViewContent reports whether viewer may see the profile details and
+// posts of owner. Users in a block relationship never see each other's
+// content. Private accounts are only visible to themselves and to their
+// followers; a pending follow request does not count.
+func (app *application) canViewContent(ctx context.Context, viewer, owner *store.User) (bool, error) {
+	if viewer != nil && viewer.ID == owner.ID {
+		return true, nil
+	}
+
+	if viewer != nil {
+		blocked, err := app.store.Blocks.IsBlocked(ctx, viewer.ID, owner.ID)
+		if err != nil || blocked {
+			return false, err
+		}
+	}
+
+	return app.canViewPrivate(ctx, viewer, owner)
+}
+
+// canViewPrivate is canViewContent for users known not to be in a block
+// relationship.
+func (app *application) canViewPrivate(ctx context.Context, viewer, owner *store.User) (bool, error) {
+	if viewer != nil && viewer.ID == owner.ID {
+		return true, nil
+	}
+
+	if !owner.IsPrivate {
+		return true, nil
+	}
+
+	if viewer == nil {
+		return false, nil
+	}
+
+	return app.store.Followers.IsFollowing(ctx, viewer.ID, owner.ID)
+}
+
 func getUserFromCtx(r *http.Request) *store.User {
 	user, _ := r.Context().Value(userCtx).(*store.User)
 
@@ -0,0 +1,5 @@
+ALTER TABLE posts
+DROP COLUMN is_hidden;
+
+ALTER TABLE comments
+DROP COLUMN is_hidden;
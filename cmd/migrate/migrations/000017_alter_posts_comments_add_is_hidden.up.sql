@@ -0,0 +1,5 @@
+ALTER TABLE posts
+ADD COLUMN is_hidden BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE comments
+ADD COLUMN is_hidden BOOLEAN NOT NULL DEFAULT FALSE;
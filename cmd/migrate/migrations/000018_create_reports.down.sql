@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_reports_status;
+DROP INDEX IF EXISTS idx_reports_reported_user_id;
+DROP TABLE IF EXISTS reports;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS reports (
+  id bigserial PRIMARY KEY,
+  reporter_id bigint NOT NULL,
+  target_type varchar(20) NOT NULL CHECK (target_type IN ('post', 'comment', 'user')),
+  target_id bigint NOT NULL,
+  reported_user_id bigint NOT NULL,
+  reason text NOT NULL,
+  status varchar(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'actioned', 'dismissed')),
+  resolved_by bigint,
+  resolved_at timestamp(0) with time zone,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  FOREIGN KEY (reporter_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (reported_user_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (resolved_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports (status, created_at);
+CREATE INDEX IF NOT EXISTS idx_reports_reported_user_id ON reports (reported_user_id);
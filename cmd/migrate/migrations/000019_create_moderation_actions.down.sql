@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_moderation_actions_report_id;
+DROP TABLE IF EXISTS moderation_actions;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS moderation_actions (
+  id bigserial PRIMARY KEY,
+  report_id bigint NOT NULL,
+  moderator_id bigint,
+  action varchar(20) NOT NULL CHECK (action IN ('hide', 'warn', 'suspend', 'dismiss')),
+  target_user_id bigint NOT NULL,
+  note text NOT NULL DEFAULT '',
+  suspend_until timestamp(0) with time zone,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  FOREIGN KEY (report_id) REFERENCES reports (id) ON DELETE CASCADE,
+  FOREIGN KEY (moderator_id) REFERENCES users (id) ON DELETE SET NULL,
+  FOREIGN KEY (target_user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_report_id ON moderation_actions (report_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_user_suspensions_user_id;
+DROP TABLE IF EXISTS user_suspensions;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS user_suspensions (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  reason text NOT NULL,
+  issued_by bigint,
+  starts_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  ends_at timestamp(0) with time zone NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (issued_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_suspensions_user_id ON user_suspensions (user_id, ends_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_notifications_user_id;
+DROP INDEX IF EXISTS idx_notifications_unread;
+DROP TABLE IF EXISTS notifications;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS notification_preferences (
+  user_id bigint NOT NULL,
+  type varchar(50) NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT TRUE,
+
+  PRIMARY KEY(user_id, type),
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_comment_mentions_user_id;
+DROP TABLE IF EXISTS comment_mentions;
+DROP INDEX IF EXISTS idx_post_mentions_user_id;
+DROP TABLE IF EXISTS post_mentions;
+DROP INDEX IF EXISTS idx_comment_hashtags_hashtag_id;
+DROP TABLE IF EXISTS comment_hashtags;
+DROP INDEX IF EXISTS idx_post_hashtags_hashtag_id;
+DROP TABLE IF EXISTS post_hashtags;
+DROP TABLE IF EXISTS hashtags;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_messages_conversation_id;
+DROP TABLE IF EXISTS messages;
+DROP INDEX IF EXISTS idx_conversation_members_user_id;
+DROP TABLE IF EXISTS conversation_members;
+DROP TABLE IF EXISTS conversations;
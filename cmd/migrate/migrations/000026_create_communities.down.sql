@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_posts_community_id;
+ALTER TABLE posts DROP COLUMN IF EXISTS community_id;
+DROP INDEX IF EXISTS idx_community_invitations_user_id;
+DROP TABLE IF EXISTS community_invitations;
+DROP INDEX IF EXISTS idx_community_members_user_id;
+DROP TABLE IF EXISTS community_members;
+DROP TABLE IF EXISTS communities;
@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS communities (
+  id bigserial PRIMARY KEY,
+  slug citext UNIQUE NOT NULL,
+  name varchar(100) NOT NULL,
+  description text NOT NULL DEFAULT '',
+  visibility varchar(20) NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'invite_only')),
+  owner_id bigint NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS community_members (
+  community_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  role varchar(20) NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'moderator', 'member')),
+  joined_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(community_id, user_id),
+  FOREIGN KEY (community_id) REFERENCES communities (id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_community_members_user_id ON community_members (user_id);
+
+CREATE TABLE IF NOT EXISTS community_invitations (
+  community_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  invited_by bigint NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(community_id, user_id),
+  FOREIGN KEY (community_id) REFERENCES communities (id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (invited_by) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_community_invitations_user_id ON community_invitations (user_id);
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS community_id bigint REFERENCES communities (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_posts_community_id ON posts (community_id, created_at) WHERE community_id IS NOT NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_poll_votes_option_id;
+DROP TABLE IF EXISTS poll_votes;
+DROP TABLE IF EXISTS poll_voters;
+DROP TABLE IF EXISTS poll_options;
+DROP TABLE IF EXISTS polls;
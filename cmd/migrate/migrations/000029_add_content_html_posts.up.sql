@@ -0,0 +1 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_html text NOT NULL DEFAULT '';
@@ -0,0 +1,11 @@
+-- Failed logins, counted per account (by email, whether or not it belongs to
+-- a user) and per IP address
+CREATE TABLE IF NOT EXISTS login_throttles (
+  scope varchar(10) NOT NULL,
+  key varchar(255) NOT NULL,
+  failures int NOT NULL DEFAULT 0,
+  last_failure_at timestamp with time zone NOT NULL DEFAULT NOW(),
+  locked_until timestamp with time zone,
+
+  PRIMARY KEY (scope, key)
+);
@@ -0,0 +1,10 @@
+-- Features turned on and off at runtime. A flag is on for the share of users
+-- given by rollout, 100 meaning everyone.
+CREATE TABLE IF NOT EXISTS feature_flags (
+  id bigserial PRIMARY KEY,
+  key varchar(100) NOT NULL UNIQUE,
+  enabled boolean NOT NULL DEFAULT false,
+  rollout int NOT NULL DEFAULT 100 CHECK (rollout BETWEEN 0 AND 100),
+  description text NOT NULL DEFAULT '',
+  updated_at timestamp with time zone NOT NULL DEFAULT NOW()
+);
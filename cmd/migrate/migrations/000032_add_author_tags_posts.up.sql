@@ -0,0 +1,9 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS author_tags varchar(100)[] NOT NULL DEFAULT '{}';
+
+-- the tags of existing posts also hold the hashtags of their content, which
+-- are not the author's
+UPDATE posts SET author_tags = ARRAY(
+  SELECT t FROM unnest(tags) t
+  WHERE lower(t) NOT IN (SELECT lower(m[1]) FROM regexp_matches(content, '#(\w+)', 'g') m)
+)
+WHERE tags IS NOT NULL;
@@ -0,0 +1,3 @@
+-- the tags as they were before normalising are not kept, so there is nothing
+-- to undo
+SELECT 1;
@@ -0,0 +1,21 @@
+-- tags saved before hashtags were normalised, the way NormalizeHashtag does
+CREATE FUNCTION pg_temp.normalize_tags(tags varchar(100)[]) RETURNS varchar(100)[] AS $$
+  SELECT COALESCE(array_agg(DISTINCT t), '{}') FROM (
+    SELECT left(lower(normalize(regexp_replace(btrim(tag), '^#', ''), NFKC)), 100) AS t
+    FROM unnest(tags) tag
+  ) normalized
+  WHERE t <> ''
+$$ LANGUAGE SQL IMMUTABLE;
+
+UPDATE posts SET tags = pg_temp.normalize_tags(tags), author_tags = pg_temp.normalize_tags(author_tags);
+
+-- posts saved before post_hashtags existed are linked to their tags too
+INSERT INTO hashtags (name)
+SELECT DISTINCT unnest(tags) FROM posts
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO post_hashtags (post_id, hashtag_id, created_at)
+SELECT p.id, h.id, p.created_at
+FROM posts p
+JOIN hashtags h ON h.name = ANY(p.tags)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// Actor is who performed a request. It is put in the request context by the
+// API once the user is authenticated and read back when recording entries.
+type Actor struct {
+	UserID    int64
+	RequestID string
+	IP        string
+}
+
+type actorKey struct{}
+
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok
+}
+
+// Entry is a single row of the append-only audit log.
+type Entry struct {
+	ID         int64           `json:"id"`
+	ActorID    *int64          `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   int64           `json:"target_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	RequestID  string          `json:"request_id"`
+	IP         string          `json:"ip"`
+	CreatedAt  string          `json:"created_at"`
+}
+
+// NewEntry builds an entry for the actor found in ctx. before and after are
+// snapshots of the target and are stored as JSON; either may be nil.
+func NewEntry(ctx context.Context, action, targetType string, targetID int64, before, after any) (*Entry, error) {
+	entry := &Entry{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+	}
+
+	if actor, ok := ActorFromContext(ctx); ok {
+		entry.ActorID = &actor.UserID
+		entry.RequestID = actor.RequestID
+		entry.IP = actor.IP
+	}
+
+	var err error
+	if entry.Before, err = marshal(before); err != nil {
+		return nil, err
+	}
+	if entry.After, err = marshal(after); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// Recorder appends entries to the audit log. When tx is not nil the entry is
+// written as part of that transaction, so it only exists if the audited
+// change was committed.
+type Recorder interface {
+	Record(ctx context.Context, tx *sql.Tx, entry *Entry) error
+}
+
+func marshal(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(v)
+}
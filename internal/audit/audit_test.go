@@ -0,0 +1,31 @@
+package audit
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNewEntry(t *testing.T) {
+	ctx := WithActor(context.Background(), Actor{UserID: 7, RequestID: "req-1", IP: "10.0.0.1"})
+
+	entry, err := NewEntry(ctx, "post.delete", "post", 3, map[string]string{"title": "hello"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.ActorID == nil || *entry.ActorID != 7 {
+		t.Errorf("expected actor 7. got %v", entry.ActorID)
+	}
+
+	if entry.RequestID != "req-1" || entry.IP != "10.0.0.1" {
+		t.Errorf("expected request id and ip from the actor. got %q %q", entry.RequestID, entry.IP)
+	}
+
+	if string(entry.Before) != `{"title":"hello"}` {
+		t.Errorf("unexpected before snapshot %s", entry.Before)
+	}
+
+	if entry.After != nil {
+		t.Errorf("expected no after snapshot. got %s", entry.After)
+	}
+}
@@ -0,0 +1,189 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var QueryTimeoutDuration = time.Second * 5
+
+type Query struct {
+	Limit      int        `json:"limit"       validate:"gte=1,lte=100"`
+	Offset     int        `json:"offset"      validate:"gte=0"`
+	ActorID    int64      `json:"actor_id"    validate:"gte=0"`
+	Action     string     `json:"action"      validate:"max=100"`
+	TargetType string     `json:"target_type" validate:"max=50"`
+	TargetID   int64      `json:"target_id"   validate:"gte=0"`
+	Since      *time.Time `json:"since"`
+	Until      *time.Time `json:"until"`
+}
+
+func (q Query) Parse(r *http.Request) (Query, error) {
+	qs := r.URL.Query()
+
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = l
+	}
+
+	if offset := qs.Get("offset"); offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return q, err
+		}
+		q.Offset = o
+	}
+
+	if actorID := qs.Get("actor_id"); actorID != "" {
+		id, err := strconv.ParseInt(actorID, 10, 64)
+		if err != nil {
+			return q, err
+		}
+		q.ActorID = id
+	}
+
+	if targetID := qs.Get("target_id"); targetID != "" {
+		id, err := strconv.ParseInt(targetID, 10, 64)
+		if err != nil {
+			return q, err
+		}
+		q.TargetID = id
+	}
+
+	if since := qs.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return q, err
+		}
+		q.Since = &t
+	}
+
+	if until := qs.Get("until"); until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return q, err
+		}
+		q.Until = &t
+	}
+
+	q.Action = qs.Get("action")
+	q.TargetType = qs.Get("target_type")
+
+	return q, nil
+}
+
+type PostgresRecorder struct {
+	db *sql.DB
+}
+
+func NewPostgresRecorder(db *sql.DB) *PostgresRecorder {
+	return &PostgresRecorder{db: db}
+}
+
+func (r *PostgresRecorder) Record(ctx context.Context, tx *sql.Tx, entry *Entry) error {
+	query := `
+  INSERT INTO audit_log (actor_id, action, target_type, target_id, before, after, request_id, ip)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+  RETURNING id, created_at
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := []any{
+		entry.ActorID,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		nullableJSON(entry.Before),
+		nullableJSON(entry.After),
+		entry.RequestID,
+		entry.IP,
+	}
+
+	var row *sql.Row
+	if tx != nil {
+		row = tx.QueryRowContext(ctx, query, args...)
+	} else {
+		row = r.db.QueryRowContext(ctx, query, args...)
+	}
+
+	return row.Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// List returns the entries matching q, most recent first.
+func (r *PostgresRecorder) List(ctx context.Context, q Query) ([]Entry, error) {
+	query := `
+  SELECT id, actor_id, action, target_type, target_id, before, after, request_id, ip, created_at
+  FROM audit_log
+  WHERE
+    ($1 = 0 OR actor_id = $1) AND
+    ($2 = '' OR action = $2) AND
+    ($3 = '' OR target_type = $3) AND
+    ($4 = 0 OR target_id = $4) AND
+    ($5::timestamptz IS NULL OR created_at >= $5) AND
+    ($6::timestamptz IS NULL OR created_at <= $6)
+  ORDER BY created_at DESC, id DESC
+  LIMIT $7 OFFSET $8
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		q.ActorID,
+		q.Action,
+		q.TargetType,
+		q.TargetID,
+		q.Since,
+		q.Until,
+		q.Limit,
+		q.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var before, after []byte
+		err := rows.Scan(
+			&e.ID,
+			&e.ActorID,
+			&e.Action,
+			&e.TargetType,
+			&e.TargetID,
+			&before,
+			&after,
+			&e.RequestID,
+			&e.IP,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		e.Before = before
+		e.After = after
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+func nullableJSON(data []byte) any {
+	if data == nil {
+		return nil
+	}
+
+	return string(data)
+}
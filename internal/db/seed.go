@@ -154,7 +154,7 @@ func generatePosts(num int, users []*store.User) []*store.Post {
 			UserID:  user.ID,
 			Title:   titles[rand.Intn(len(titles))],
 			Content: contents[rand.Intn(len(contents))],
-			Tags: []string{
+			AuthorTags: []string{
 				tags[rand.Intn(len(tags))],
 				tags[rand.Intn(len(tags))],
 			},
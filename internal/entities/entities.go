@@ -0,0 +1,100 @@
+// Package entities finds @mentions and #hashtags in user content.
+package entities
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	TypeMention = "mention"
+	TypeHashtag = "hashtag"
+)
+
+// maxLength bounds usernames and hashtags, matching the longest username
+// accepted at registration.
+const maxLength = 100
+
+var (
+	// a mention or hashtag starts the text or follows a character that can't
+	// be part of it, so emails and URL fragments are left alone
+	mentionRe = regexp.MustCompile(`(?:^|[^\w@])@(\w{1,100})`)
+	hashtagRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_#&])#([\p{L}\p{N}_]{1,100})`)
+)
+
+// Entity is a mention or a hashtag found in a text. Start and End are offsets
+// in Unicode code points, End excluded, and include the leading @ or #. Value
+// is the username for mentions and the normalised tag for hashtags.
+type Entity struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Parse returns the entities of text in the order they appear.
+func Parse(text string) []Entity {
+	entities := []Entity{}
+
+	for _, m := range mentionRe.FindAllStringSubmatchIndex(text, -1) {
+		entities = append(entities, newEntity(text, TypeMention, text[m[2]:m[3]], m[2]-1, m[3]))
+	}
+
+	for _, m := range hashtagRe.FindAllStringSubmatchIndex(text, -1) {
+		tag := text[m[2]:m[3]]
+		// #2024 is a number, not a tag
+		if strings.IndexFunc(tag, unicode.IsLetter) < 0 {
+			continue
+		}
+
+		entities = append(entities, newEntity(text, TypeHashtag, NormalizeHashtag(tag), m[2]-1, m[3]))
+	}
+
+	slices.SortFunc(entities, func(a, b Entity) int { return a.Start - b.Start })
+
+	return entities
+}
+
+// Values returns the distinct values of the entities of the given type.
+func Values(entities []Entity, entityType string) []string {
+	seen := make(map[string]bool)
+	values := []string{}
+
+	for _, e := range entities {
+		if e.Type != entityType || seen[e.Value] {
+			continue
+		}
+
+		seen[e.Value] = true
+		values = append(values, e.Value)
+	}
+
+	return values
+}
+
+// NormalizeHashtag folds the different ways of writing a tag into one, so
+// #GoLang and #golang, or composed and decomposed accents, are the same tag.
+func NormalizeHashtag(tag string) string {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+	tag = strings.ToLower(norm.NFKC.String(tag))
+
+	if utf8.RuneCountInString(tag) > maxLength {
+		tag = string([]rune(tag)[:maxLength])
+	}
+
+	return tag
+}
+
+func newEntity(text, entityType, value string, start, end int) Entity {
+	return Entity{
+		Type:  entityType,
+		Value: value,
+		Start: utf8.RuneCountInString(text[:start]),
+		End:   utf8.RuneCountInString(text[:end]),
+	}
+}
@@ -0,0 +1,55 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Entity
+	}{
+		{
+			name: "mentions and hashtags",
+			text: "hi @alice, see #GoLang",
+			want: []Entity{
+				{Type: TypeMention, Value: "alice", Start: 3, End: 9},
+				{Type: TypeHashtag, Value: "golang", Start: 15, End: 22},
+			},
+		},
+		{
+			name: "offsets in code points",
+			text: "héllo 👋 #Café",
+			want: []Entity{
+				{Type: TypeHashtag, Value: "café", Start: 8, End: 13},
+			},
+		},
+		{
+			name: "emails, numbers and entities are ignored",
+			text: "mail bob@example.com about issue #42 &#39;",
+			want: []Entity{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v. got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValues(t *testing.T) {
+	entities := Parse("#go @bob #Go #rust @bob")
+
+	if got := Values(entities, TypeHashtag); !reflect.DeepEqual(got, []string{"go", "rust"}) {
+		t.Errorf("unexpected hashtags %v", got)
+	}
+
+	if got := Values(entities, TypeMention); !reflect.DeepEqual(got, []string{"bob"}) {
+		t.Errorf("unexpected mentions %v", got)
+	}
+}
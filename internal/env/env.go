@@ -1,8 +1,10 @@
 package env
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 )
 
 func GetString(key string, fallback string) string {
@@ -41,3 +43,36 @@ func GetBool(key string, fallback bool) bool {
 
 	return valAsBool
 }
+
+// GetSecret reads a secret from the file named by key with a _FILE suffix,
+// such as a mounted Docker or Kubernetes secret, or else from key itself.
+func GetSecret(key string, fallback string) (string, error) {
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return GetString(key, fallback), nil
+	}
+
+	val, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading %s_FILE: %w", key, err)
+	}
+
+	return strings.TrimRight(string(val), "\r\n"), nil
+}
+
+// Parse parses the value of key, or returns fallback when it isn't set.
+// Unlike the Get functions, it reports values that don't parse instead of
+// falling back.
+func Parse[T any](key string, fallback T, parse func(string) (T, error)) (T, error) {
+	val := os.Getenv(key)
+	if val == "" {
+		return fallback, nil
+	}
+
+	parsed, err := parse(val)
+	if err != nil {
+		return fallback, fmt.Errorf("%s: invalid value %q", key, val)
+	}
+
+	return parsed, nil
+}
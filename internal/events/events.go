@@ -0,0 +1,170 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Event types published to user topics.
+const (
+	TypeFeedPost         = "feed.post"
+	TypeNotification     = "notification"
+	TypeCommentCreated   = "comment.created"
+	TypeMessage          = "message.created"
+	TypeConversationRead = "conversation.read"
+)
+
+// Event types published to post topics, along with TypeCommentCreated.
+const (
+	TypeCommentDeleted = "comment.deleted"
+	TypeTyping         = "comment.typing"
+	TypePresence       = "presence"
+)
+
+// ErrSlowConsumer closes subscriptions that can't keep up. Clients are
+// expected to reconnect and resume from the last event they received.
+var ErrSlowConsumer = errors.New("subscriber is too slow")
+
+// subscriptionBuffer is how many events may wait for a subscriber before it
+// is considered too slow.
+const subscriptionBuffer = 64
+
+// Event is a message published on a topic. ID is assigned by the broker and
+// increases within a topic, so subscribers can resume after it. Transient
+// events, such as typing indicators, are only delivered to current
+// subscribers: they get no ID and are never replayed.
+type Event struct {
+	ID        string          `json:"id,omitempty"`
+	Topic     string          `json:"topic"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	Transient bool            `json:"transient,omitempty"`
+}
+
+// NewEvent builds an event of the given type with data encoded as JSON.
+func NewEvent(eventType string, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{Type: eventType, Data: raw}, nil
+}
+
+// Broker is the internal pub/sub used to push events to connected clients.
+type Broker interface {
+	Publish(ctx context.Context, topic string, event Event) error
+	Subscribe(ctx context.Context, topic string) (*Subscription, error)
+	// Replay returns the events published on topic after lastEventID that
+	// are still retained by the broker, oldest first.
+	Replay(ctx context.Context, topic, lastEventID string) ([]Event, error)
+	Close() error
+}
+
+func UserTopic(userID int64) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+func PostTopic(postID int64) string {
+	return fmt.Sprintf("post:%d", postID)
+}
+
+type Subscription struct {
+	C <-chan Event
+
+	c      chan Event
+	topic  string
+	hub    *hub
+	once   sync.Once
+	errMu  sync.Mutex
+	closed error
+}
+
+// Err reports why the subscription was closed by the broker, if it was.
+func (s *Subscription) Err() error {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	return s.closed
+}
+
+func (s *Subscription) Close() {
+	s.hub.remove(s, nil)
+}
+
+// hub fans events out to the local subscribers of each topic. Both brokers
+// use it; the Redis broker feeds it from a single pub/sub connection.
+type hub struct {
+	mu     sync.Mutex
+	topics map[string]map[*Subscription]struct{}
+}
+
+func newHub() *hub {
+	return &hub{topics: make(map[string]map[*Subscription]struct{})}
+}
+
+func (h *hub) add(topic string) *Subscription {
+	c := make(chan Event, subscriptionBuffer)
+	sub := &Subscription{C: c, c: c, topic: topic, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[*Subscription]struct{})
+	}
+	h.topics[topic][sub] = struct{}{}
+
+	return sub
+}
+
+func (h *hub) remove(sub *Subscription, reason error) {
+	sub.once.Do(func() {
+		h.mu.Lock()
+		delete(h.topics[sub.topic], sub)
+		if len(h.topics[sub.topic]) == 0 {
+			delete(h.topics, sub.topic)
+		}
+		h.mu.Unlock()
+
+		sub.errMu.Lock()
+		sub.closed = reason
+		sub.errMu.Unlock()
+
+		close(sub.c)
+	})
+}
+
+func (h *hub) dispatch(event Event) {
+	h.mu.Lock()
+	var slow []*Subscription
+	for sub := range h.topics[event.Topic] {
+		select {
+		case sub.c <- event:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, sub := range slow {
+		h.remove(sub, ErrSlowConsumer)
+	}
+}
+
+func (h *hub) closeAll() {
+	h.mu.Lock()
+	var subs []*Subscription
+	for _, topic := range h.topics {
+		for sub := range topic {
+			subs = append(subs, sub)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, sub := range subs {
+		h.remove(sub, nil)
+	}
+}
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// historySize is how many events per topic are kept for Last-Event-ID
+// replays.
+const historySize = 100
+
+// MemoryBroker delivers events within a single process.
+type MemoryBroker struct {
+	hub *hub
+
+	mu      sync.Mutex
+	lastID  uint64
+	history map[string][]Event
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		hub:     newHub(),
+		history: make(map[string][]Event),
+	}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, topic string, event Event) error {
+	event.Topic = topic
+
+	if !event.Transient {
+		b.mu.Lock()
+		b.lastID++
+		event.ID = strconv.FormatUint(b.lastID, 10)
+
+		history := append(b.history[topic], event)
+		if len(history) > historySize {
+			history = history[len(history)-historySize:]
+		}
+		b.history[topic] = history
+		b.mu.Unlock()
+	}
+
+	b.hub.dispatch(event)
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, topic string) (*Subscription, error) {
+	return b.hub.add(topic), nil
+}
+
+func (b *MemoryBroker) Replay(ctx context.Context, topic, lastEventID string) ([]Event, error) {
+	last, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil {
+		return nil, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	for _, event := range b.history[topic] {
+		id, _ := strconv.ParseUint(event.ID, 10, 64)
+		if id > last {
+			missed = append(missed, event)
+		}
+	}
+
+	return missed, nil
+}
+
+func (b *MemoryBroker) Close() error {
+	b.hub.closeAll()
+	return nil
+}
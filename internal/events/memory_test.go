@@ -0,0 +1,88 @@
+package events
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	ctx := context.Background()
+	broker := NewMemoryBroker()
+	defer broker.Close()
+
+	sub, err := broker.Subscribe(ctx, UserTopic(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	for _, eventType := range []string{TypeFeedPost, TypeNotification, TypeCommentCreated} {
+		event, err := NewEvent(eventType, map[string]int{"id": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := broker.Publish(ctx, UserTopic(1), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := broker.Publish(ctx, UserTopic(2), Event{Type: TypeFeedPost}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should deliver events of the subscribed topic only", func(t *testing.T) {
+		if len(sub.C) != 3 {
+			t.Fatalf("expected 3 events. got %d", len(sub.C))
+		}
+
+		first := <-sub.C
+		if first.Type != TypeFeedPost || first.Topic != UserTopic(1) {
+			t.Errorf("unexpected first event %+v", first)
+		}
+	})
+
+	t.Run("should replay events after the last event id", func(t *testing.T) {
+		missed, err := broker.Replay(ctx, UserTopic(1), "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(missed) != 2 || missed[0].Type != TypeNotification || missed[1].Type != TypeCommentCreated {
+			t.Errorf("unexpected replay %+v", missed)
+		}
+	})
+
+	t.Run("should not keep transient events", func(t *testing.T) {
+		typing, _ := broker.Subscribe(ctx, PostTopic(1))
+		defer typing.Close()
+
+		if err := broker.Publish(ctx, PostTopic(1), Event{Type: TypeTyping, Transient: true}); err != nil {
+			t.Fatal(err)
+		}
+
+		if event := <-typing.C; event.ID != "" {
+			t.Errorf("expected no id for a transient event. got %q", event.ID)
+		}
+
+		missed, _ := broker.Replay(ctx, PostTopic(1), "0")
+		if len(missed) != 0 {
+			t.Errorf("expected nothing to replay. got %+v", missed)
+		}
+	})
+
+	t.Run("should close slow subscribers", func(t *testing.T) {
+		slow, _ := broker.Subscribe(ctx, UserTopic(3))
+
+		for range subscriptionBuffer + 1 {
+			_ = broker.Publish(ctx, UserTopic(3), Event{Type: TypeFeedPost})
+		}
+
+		for range slow.C {
+		}
+
+		if slow.Err() != ErrSlowConsumer {
+			t.Errorf("expected ErrSlowConsumer. got %v", slow.Err())
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// PresenceTTL is how long a client stays counted without joining again.
+// Connected clients refresh their presence well within it, so the ones lost
+// with a crashed instance drop out on their own.
+const PresenceTTL = time.Minute
+
+const presencePrefix = "presence:"
+
+// Presence counts the clients connected to a topic.
+type Presence interface {
+	// Join counts clientID on topic, or refreshes it, and returns how many
+	// clients are connected.
+	Join(ctx context.Context, topic, clientID string) (int64, error)
+	Leave(ctx context.Context, topic, clientID string) (int64, error)
+}
+
+// MemoryPresence counts the clients of a single process.
+type MemoryPresence struct {
+	mu      sync.Mutex
+	clients map[string]map[string]struct{}
+}
+
+func NewMemoryPresence() *MemoryPresence {
+	return &MemoryPresence{clients: make(map[string]map[string]struct{})}
+}
+
+func (p *MemoryPresence) Join(ctx context.Context, topic, clientID string) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.clients[topic] == nil {
+		p.clients[topic] = make(map[string]struct{})
+	}
+	p.clients[topic][clientID] = struct{}{}
+
+	return int64(len(p.clients[topic])), nil
+}
+
+func (p *MemoryPresence) Leave(ctx context.Context, topic, clientID string) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.clients[topic], clientID)
+	count := len(p.clients[topic])
+	if count == 0 {
+		delete(p.clients, topic)
+	}
+
+	return int64(count), nil
+}
+
+// RedisPresence shares counts between instances. Each topic is a sorted set
+// of client IDs scored by when their presence expires.
+type RedisPresence struct {
+	rdb *redis.Client
+}
+
+func NewRedisPresence(rdb *redis.Client) *RedisPresence {
+	return &RedisPresence{rdb: rdb}
+}
+
+func (p *RedisPresence) Join(ctx context.Context, topic, clientID string) (int64, error) {
+	key := presencePrefix + topic
+	expiresAt := time.Now().Add(PresenceTTL).Unix()
+
+	pipe := p.rdb.TxPipeline()
+	pipe.ZAdd(ctx, key, &redis.Z{Score: float64(expiresAt), Member: clientID})
+	pipe.Expire(ctx, key, PresenceTTL)
+
+	return p.count(ctx, pipe, key)
+}
+
+func (p *RedisPresence) Leave(ctx context.Context, topic, clientID string) (int64, error) {
+	key := presencePrefix + topic
+
+	pipe := p.rdb.TxPipeline()
+	pipe.ZRem(ctx, key, clientID)
+
+	return p.count(ctx, pipe, key)
+}
+
+// count drops the expired clients and counts the others as part of pipe.
+func (p *RedisPresence) count(ctx context.Context, pipe redis.Pipeliner, key string) (int64, error) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	pipe.ZRemRangeByScore(ctx, key, "-inf", "("+now)
+	card := pipe.ZCard(ctx, key)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return card.Val(), nil
+}
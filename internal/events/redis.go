@@ -0,0 +1,132 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	channelPrefix = "events:"
+	streamPrefix  = "events-history:"
+)
+
+// RedisBroker lets several API instances share events. Every event is
+// appended to a capped Redis stream per topic, which gives it an ordered ID
+// and keeps it for replays, then published on Redis pub/sub. Each instance
+// holds one pattern subscription and fans events out to its own clients.
+type RedisBroker struct {
+	rdb    *redis.Client
+	hub    *hub
+	pubsub *redis.PubSub
+	done   chan struct{}
+}
+
+func NewRedisBroker(rdb *redis.Client) *RedisBroker {
+	b := &RedisBroker{
+		rdb:    rdb,
+		hub:    newHub(),
+		pubsub: rdb.PSubscribe(context.Background(), channelPrefix+"*"),
+		done:   make(chan struct{}),
+	}
+
+	go b.listen()
+
+	return b
+}
+
+func (b *RedisBroker) listen() {
+	defer close(b.done)
+
+	for msg := range b.pubsub.Channel() {
+		var event Event
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			continue
+		}
+		event.Topic = strings.TrimPrefix(msg.Channel, channelPrefix)
+
+		b.hub.dispatch(event)
+	}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, topic string, event Event) error {
+	if !event.Transient {
+		id, err := b.rdb.XAdd(ctx, &redis.XAddArgs{
+			Stream: streamPrefix + topic,
+			MaxLen: historySize,
+			Approx: true,
+			Values: map[string]any{"type": event.Type, "data": string(event.Data)},
+		}).Result()
+		if err != nil {
+			return err
+		}
+
+		event.ID = id
+	}
+	event.Topic = topic
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return b.rdb.Publish(ctx, channelPrefix+topic, payload).Err()
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, topic string) (*Subscription, error) {
+	return b.hub.add(topic), nil
+}
+
+func (b *RedisBroker) Replay(ctx context.Context, topic, lastEventID string) ([]Event, error) {
+	if !isStreamID(lastEventID) {
+		return nil, nil
+	}
+
+	messages, err := b.rdb.XRange(ctx, streamPrefix+topic, "("+lastEventID, "+").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	missed := make([]Event, 0, len(messages))
+	for _, msg := range messages {
+		eventType, _ := msg.Values["type"].(string)
+		data, _ := msg.Values["data"].(string)
+
+		missed = append(missed, Event{
+			ID:    msg.ID,
+			Topic: topic,
+			Type:  eventType,
+			Data:  json.RawMessage(data),
+		})
+	}
+
+	return missed, nil
+}
+
+// isStreamID reports whether id is a Redis stream ID, <ms>-<seq>. Anything
+// else sent as Last-Event-ID is ignored rather than passed on to XRANGE.
+func isStreamID(id string) bool {
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return false
+	}
+
+	_, err := strconv.ParseUint(ms, 10, 64)
+	if err != nil {
+		return false
+	}
+	_, err = strconv.ParseUint(seq, 10, 64)
+
+	return err == nil
+}
+
+func (b *RedisBroker) Close() error {
+	err := b.pubsub.Close()
+	<-b.done
+	b.hub.closeAll()
+
+	return err
+}
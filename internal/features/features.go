@@ -0,0 +1,216 @@
+// Package features turns features on and off at runtime. Flags are kept in
+// the database and cached by every instance, which reloads them when another
+// instance announces a change and, in case an announcement is missed, every
+// interval.
+package features
+
+import (
+	"context"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/events"
+	"github.com/shanisharrma/gopher-social/internal/store"
+	"go.uber.org/zap"
+)
+
+// Flags checked by the API.
+const (
+	Registration = "registration"
+	RateLimiter  = "rate_limiter"
+)
+
+// defaults apply to the flags checked by the API until they are set. Other
+// flags are off until set.
+var defaults = map[string]bool{
+	Registration: true,
+	RateLimiter:  true,
+}
+
+// killSwitches are the flags turning off what is on for everyone, like rate
+// limiting or registration, which also cover anonymous requests. They can't
+// be rolled out to a percentage of users, as anonymous requests would never
+// get them.
+var killSwitches = map[string]bool{
+	Registration: true,
+	RateLimiter:  true,
+}
+
+// IsKillSwitch reports whether a flag is either on or off for everyone.
+func IsKillSwitch(key string) bool {
+	return killSwitches[key]
+}
+
+const (
+	// changesTopic is where instances announce the changes they make.
+	changesTopic = "feature_flags"
+	typeChanged  = "feature_flags.changed"
+)
+
+// Source loads the flags, see store.FeatureFlagStore.
+type Source interface {
+	GetAll(ctx context.Context) ([]store.FeatureFlag, error)
+}
+
+type Flags struct {
+	source   Source
+	broker   events.Broker
+	interval time.Duration
+	logger   *zap.SugaredLogger
+
+	mu    sync.RWMutex
+	flags map[string]store.FeatureFlag
+}
+
+func New(source Source, broker events.Broker, interval time.Duration, logger *zap.SugaredLogger) *Flags {
+	return &Flags{
+		source:   source,
+		broker:   broker,
+		interval: interval,
+		logger:   logger,
+		flags:    make(map[string]store.FeatureFlag),
+	}
+}
+
+// Enabled reports whether a flag is on for a user. Anonymous requests, with
+// a userID of 0, only get the flags rolled out to everyone.
+func (f *Flags) Enabled(key string, userID int64) bool {
+	f.mu.RLock()
+	flag, ok := f.flags[key]
+	f.mu.RUnlock()
+
+	if !ok {
+		return defaults[key]
+	}
+
+	if !flag.Enabled {
+		return false
+	}
+	if flag.Rollout >= 100 || killSwitches[key] {
+		return true
+	}
+	if userID == 0 {
+		return false
+	}
+
+	return bucket(key, userID) < flag.Rollout
+}
+
+// bucket places a user in one of 100 buckets, differently for each flag so
+// that the same users aren't always the first to get new features.
+func bucket(key string, userID int64) int {
+	h := fnv.New32a()
+	h.Write([]byte(key + ":" + strconv.FormatInt(userID, 10)))
+	return int(h.Sum32() % 100)
+}
+
+// All returns the flags in effect, by key, including the defaults of those
+// that were never set.
+func (f *Flags) All() []store.FeatureFlag {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	all := make([]store.FeatureFlag, 0, len(f.flags)+len(defaults))
+	for _, flag := range f.flags {
+		all = append(all, flag)
+	}
+	for key, enabled := range defaults {
+		if _, ok := f.flags[key]; !ok {
+			all = append(all, store.FeatureFlag{Key: key, Enabled: enabled, Rollout: 100})
+		}
+	}
+
+	slices.SortFunc(all, func(a, b store.FeatureFlag) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return all
+}
+
+// Refresh reloads the flags from the source.
+func (f *Flags) Refresh(ctx context.Context) error {
+	flags, err := f.source.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	byKey := make(map[string]store.FeatureFlag, len(flags))
+	for _, flag := range flags {
+		byKey[flag.Key] = flag
+	}
+
+	f.mu.Lock()
+	f.flags = byKey
+	f.mu.Unlock()
+
+	return nil
+}
+
+// Changed reloads the flags after a change and announces it to the other
+// instances.
+func (f *Flags) Changed(ctx context.Context) error {
+	if err := f.Refresh(ctx); err != nil {
+		return err
+	}
+
+	event, err := events.NewEvent(typeChanged, nil)
+	if err != nil {
+		return err
+	}
+	event.Transient = true
+
+	return f.broker.Publish(ctx, changesTopic, event)
+}
+
+// Run reloads the flags whenever a change is announced, and every interval,
+// until ctx is done.
+func (f *Flags) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	sub := f.subscribe(ctx)
+	defer func() {
+		if sub != nil {
+			sub.Close()
+		}
+	}()
+
+	for {
+		var changes <-chan events.Event
+		if sub != nil {
+			changes = sub.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if sub == nil {
+				sub = f.subscribe(ctx)
+			}
+		case _, ok := <-changes:
+			if !ok {
+				// dropped by the broker, subscribe again on the next tick
+				sub = nil
+			}
+		}
+
+		if err := f.Refresh(ctx); err != nil {
+			f.logger.Errorw("error refreshing feature flags", "error", err)
+		}
+	}
+}
+
+func (f *Flags) subscribe(ctx context.Context) *events.Subscription {
+	sub, err := f.broker.Subscribe(ctx, changesTopic)
+	if err != nil {
+		f.logger.Errorw("error subscribing to feature flag changes", "error", err)
+		return nil
+	}
+
+	return sub
+}
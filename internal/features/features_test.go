@@ -0,0 +1,103 @@
+package features
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/events"
+	"github.com/shanisharrma/gopher-social/internal/store"
+	"go.uber.org/zap"
+)
+
+func TestFlags(t *testing.T) {
+	ctx := context.Background()
+
+	newFlags := func(t *testing.T) (*Flags, *store.MockFeatureFlagStore) {
+		source := store.NewMockStore().FeatureFlags.(*store.MockFeatureFlagStore)
+		broker := events.NewMemoryBroker()
+		t.Cleanup(func() { broker.Close() })
+
+		return New(source, broker, time.Hour, zap.NewNop().Sugar()), source
+	}
+
+	t.Run("should use the defaults of flags never set", func(t *testing.T) {
+		flags, _ := newFlags(t)
+
+		if !flags.Enabled(Registration, 0) {
+			t.Error("expected registration to be on by default")
+		}
+		if flags.Enabled("unknown", 1) {
+			t.Error("expected unknown flags to be off")
+		}
+	})
+
+	t.Run("should roll out to a share of users", func(t *testing.T) {
+		flags, source := newFlags(t)
+		if err := source.Set(ctx, &store.FeatureFlag{Key: "new_feed", Enabled: true, Rollout: 30}); err != nil {
+			t.Fatal(err)
+		}
+		if err := flags.Refresh(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		on := 0
+		for userID := int64(1); userID <= 1000; userID++ {
+			if flags.Enabled("new_feed", userID) {
+				on++
+			}
+			if flags.Enabled("new_feed", userID) != flags.Enabled("new_feed", userID) {
+				t.Fatalf("expected user %d to always get the same answer", userID)
+			}
+		}
+		if on < 250 || on > 350 {
+			t.Errorf("expected about 300 users out of 1000; got %d", on)
+		}
+		if flags.Enabled("new_feed", 0) {
+			t.Error("expected anonymous requests to be left out of partial rollouts")
+		}
+	})
+
+	t.Run("should keep kill switches on for anonymous requests", func(t *testing.T) {
+		flags, source := newFlags(t)
+		if err := source.Set(ctx, &store.FeatureFlag{Key: RateLimiter, Enabled: true, Rollout: 30}); err != nil {
+			t.Fatal(err)
+		}
+		if err := flags.Refresh(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if !flags.Enabled(RateLimiter, 0) {
+			t.Error("expected the rate limiter to stay on for anonymous requests")
+		}
+	})
+
+	t.Run("should reload when another instance announces a change", func(t *testing.T) {
+		source := store.NewMockStore().FeatureFlags.(*store.MockFeatureFlagStore)
+		broker := events.NewMemoryBroker()
+		t.Cleanup(func() { broker.Close() })
+
+		flags := New(source, broker, time.Hour, zap.NewNop().Sugar())
+		other := New(source, broker, time.Hour, zap.NewNop().Sugar())
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go flags.Run(ctx)
+
+		if err := source.Set(ctx, &store.FeatureFlag{Key: Registration, Enabled: false, Rollout: 100}); err != nil {
+			t.Fatal(err)
+		}
+
+		// announced until received, as Run may not have subscribed yet
+		deadline := time.Now().Add(time.Second)
+		for flags.Enabled(Registration, 1) {
+			if time.Now().After(deadline) {
+				t.Fatal("expected registration to be turned off")
+			}
+			if err := other.Changed(ctx); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+var errDraining = errors.New("server is shutting down")
+
+// Check reports whether a dependency can be used.
+type Check func(ctx context.Context) error
+
+type Result struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs the checks of the dependencies an instance needs to serve
+// requests. Results are cached for a while, so frequent probes from several
+// load balancers don't add load on the dependencies.
+type Checker struct {
+	ttl      time.Duration
+	checks   []*check
+	draining atomic.Bool
+}
+
+type check struct {
+	name    string
+	timeout time.Duration
+	run     Check
+
+	mu        sync.Mutex
+	result    Result
+	checkedAt time.Time
+}
+
+func NewChecker(ttl time.Duration) *Checker {
+	return &Checker{ttl: ttl}
+}
+
+// Register adds a check, which fails when it takes longer than timeout.
+// Checks must be registered before the checker is used.
+func (c *Checker) Register(name string, timeout time.Duration, run Check) {
+	c.checks = append(c.checks, &check{name: name, timeout: timeout, run: run})
+}
+
+// Drain makes the checker report the instance as down from now on, so load
+// balancers stop sending it requests while it shuts down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Check runs the checks concurrently, or reuses their recent results, and
+// reports the instance as up when they all pass.
+func (c *Checker) Check(ctx context.Context) Report {
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(c.checks))}
+
+	if c.draining.Load() {
+		report.Status = StatusDown
+		report.Checks["shutdown"] = Result{Status: StatusDown, Error: errDraining.Error()}
+		return report
+	}
+
+	results := make([]Result, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = check.check(ctx, c.ttl)
+		}()
+	}
+	wg.Wait()
+
+	for i, check := range c.checks {
+		report.Checks[check.name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	return report
+}
+
+func (c *check) check(ctx context.Context, ttl time.Duration) Result {
+	// concurrent probes wait for the check in flight rather than starting
+	// their own
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < ttl {
+		return c.result
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.runWithTimeout(ctx)
+
+	c.result = Result{Status: StatusUp, Latency: time.Since(start).String()}
+	if err != nil {
+		c.result.Status = StatusDown
+		c.result.Error = err.Error()
+	}
+	c.checkedAt = time.Now()
+
+	return c.result
+}
+
+// runWithTimeout returns once ctx is done, even when the check doesn't
+// honour it.
+func (c *check) runWithTimeout(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- c.run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,74 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChecker(t *testing.T) {
+	t.Run("should report every check", func(t *testing.T) {
+		c := NewChecker(0)
+		c.Register("db", time.Second, func(ctx context.Context) error { return nil })
+		c.Register("cache", time.Second, func(ctx context.Context) error { return errors.New("connection refused") })
+
+		report := c.Check(context.Background())
+
+		if report.Status != StatusDown {
+			t.Errorf("expected status %q; got %q", StatusDown, report.Status)
+		}
+		if got := report.Checks["db"].Status; got != StatusUp {
+			t.Errorf("expected db to be %q; got %q", StatusUp, got)
+		}
+		if got := report.Checks["cache"]; got.Status != StatusDown || got.Error != "connection refused" {
+			t.Errorf("expected cache to be down with its error; got %+v", got)
+		}
+	})
+
+	t.Run("should time out slow checks", func(t *testing.T) {
+		c := NewChecker(0)
+		c.Register("slow", 10*time.Millisecond, func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		})
+
+		start := time.Now()
+		report := c.Check(context.Background())
+
+		if report.Status != StatusDown {
+			t.Errorf("expected status %q; got %q", StatusDown, report.Status)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("expected the check to give up after its timeout; took %s", elapsed)
+		}
+	})
+
+	t.Run("should cache results", func(t *testing.T) {
+		calls := 0
+		c := NewChecker(time.Minute)
+		c.Register("db", time.Second, func(ctx context.Context) error {
+			calls++
+			return nil
+		})
+
+		c.Check(context.Background())
+		c.Check(context.Background())
+
+		if calls != 1 {
+			t.Errorf("expected the check to run once; ran %d times", calls)
+		}
+	})
+
+	t.Run("should report down while draining", func(t *testing.T) {
+		c := NewChecker(0)
+		c.Register("db", time.Second, func(ctx context.Context) error { return nil })
+		c.Drain()
+
+		report := c.Check(context.Background())
+
+		if report.Status != StatusDown {
+			t.Errorf("expected status %q; got %q", StatusDown, report.Status)
+		}
+	})
+}
@@ -1,16 +1,51 @@
 package mailer
 
-import "embed"
+import (
+	"context"
+	"embed"
+
+	"github.com/shanisharrma/gopher-social/internal/metrics"
+	"github.com/shanisharrma/gopher-social/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
 
 var (
-	FromName            = "GopherSocial"
-	maxRetries          = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	FromName               = "GopherSocial"
+	maxRetries             = 3
+	UserWelcomeTemplate    = "user_invitation.tmpl"
+	ReportResolvedTemplate = "report_resolved.tmpl"
+	UserWarningTemplate    = "user_warning.tmpl"
+	LoginAlertTemplate     = "login_alert.tmpl"
 )
 
 //go:embed "templates"
 var FS embed.FS
 
 type Client interface {
-	Send(templateFile, username, email string, data any, isSandbox bool) (int, error)
+	Send(ctx context.Context, templateFile, username, email string, data any, isSandbox bool) (int, error)
+}
+
+// instrument runs send, the delivery of an email by provider, within a span
+// and counts its outcome.
+func instrument(ctx context.Context, provider, templateFile string, send func() (int, error)) (int, error) {
+	_, span := tracing.Tracer().Start(ctx, "mailer.Send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("mailer.provider", provider),
+			attribute.String("mailer.template", templateFile),
+		),
+	)
+	defer span.End()
+
+	status, err := send()
+	metrics.EmailsSent.WithLabelValues(templateFile, metrics.Outcome(err)).Inc()
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return status, err
 }
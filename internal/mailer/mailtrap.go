@@ -2,6 +2,7 @@ package mailer
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"html/template"
 
@@ -26,7 +27,13 @@ func NewMailtrap(apiKey, username, fromEmail string) (*MailtrapClient, error) {
 	}, nil
 }
 
-func (m *MailtrapClient) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+func (m *MailtrapClient) Send(ctx context.Context, templateFile, username, email string, data any, isSandbox bool) (int, error) {
+	return instrument(ctx, "mailtrap", templateFile, func() (int, error) {
+		return m.send(templateFile, email, data)
+	})
+}
+
+func (m *MailtrapClient) send(templateFile, email string, data any) (int, error) {
 	// Template parsing and building
 	tmpl, err := template.ParseFS(FS, "templates/"+templateFile)
 	if err != nil {
@@ -50,10 +57,44 @@ func (m *MailtrapClient) Send(templateFile, username, email string, data any, is
 
 	message.AddAlternative("text/html", body.String())
 
-	dialer := gomail.NewDialer("sandbox.smtp.mailtrap.io", 587, m.Username, m.ApiKey)
-	if err := dialer.DialAndSend(message); err != nil {
+	if err := m.dialer().DialAndSend(message); err != nil {
 		return -1, err
 	}
 
 	return 200, nil
 }
+
+// Ping connects and authenticates to the SMTP server without sending
+// anything. It returns when ctx is done, even if the server hasn't answered.
+func (m *MailtrapClient) Ping(ctx context.Context) error {
+	type result struct {
+		conn gomail.SendCloser
+		err  error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		conn, err := m.dialer().Dial()
+		done <- result{conn, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return res.err
+		}
+		return res.conn.Close()
+	case <-ctx.Done():
+		// gomail can't interrupt a dial, close the connection once it is up
+		go func() {
+			if res := <-done; res.err == nil {
+				res.conn.Close()
+			}
+		}()
+		return ctx.Err()
+	}
+}
+
+func (m *MailtrapClient) dialer() *gomail.Dialer {
+	return gomail.NewDialer("sandbox.smtp.mailtrap.io", 587, m.Username, m.ApiKey)
+}
@@ -0,0 +1,13 @@
+package mailer
+
+import "context"
+
+func NewMockClient() Client {
+	return &MockClient{}
+}
+
+type MockClient struct{}
+
+func (m MockClient) Send(ctx context.Context, templateFile, username, email string, data any, isSandbox bool) (int, error) {
+	return 200, nil
+}
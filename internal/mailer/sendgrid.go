@@ -2,6 +2,7 @@ package mailer
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"html/template"
 	"time"
@@ -26,7 +27,13 @@ func NewSendGrid(fromEmail, apiKey string) *SendGridMailer {
 	}
 }
 
-func (m *SendGridMailer) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+func (m *SendGridMailer) Send(ctx context.Context, templateFile, username, email string, data any, isSandbox bool) (int, error) {
+	return instrument(ctx, "sendgrid", templateFile, func() (int, error) {
+		return m.send(templateFile, username, email, data, isSandbox)
+	})
+}
+
+func (m *SendGridMailer) send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
 	from := mail.NewEmail(FromName, m.fromEmail)
 	to := mail.NewEmail(username, email)
 
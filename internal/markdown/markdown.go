@@ -0,0 +1,120 @@
+// Package markdown turns user written Markdown into HTML that is safe to embed
+// in a page.
+package markdown
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// linkRel is set on every link of user content, so that search engines don't
+// credit the linked pages.
+const linkRel = "nofollow ugc"
+
+// md supports CommonMark with strikethrough and bare URLs turned into links.
+// Raw HTML is left out.
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.Strikethrough, extension.Linkify),
+	goldmark.WithParserOptions(
+		parser.WithASTTransformers(util.Prioritized(linkTransformer{}, 100)),
+	),
+)
+
+// policy is the allow-list of what rendered content may contain. Whatever
+// Markdown produces outside of it, such as headings or images, is reduced to
+// its text.
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements(
+		"p", "br", "hr",
+		"em", "strong", "del",
+		"code", "pre", "blockquote",
+		"ul", "ol", "li",
+	)
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("rel").Matching(regexp.MustCompile(`^` + linkRel + `$`)).OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+
+	return p
+}
+
+// Render converts Markdown to sanitised HTML.
+func Render(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+
+	return Sanitize(buf.String()), nil
+}
+
+// Sanitize strips from html everything outside of the allow-list. Links keep
+// only safe URLs and are marked nofollow.
+func Sanitize(html string) string {
+	return policy.Sanitize(html)
+}
+
+// linkTransformer sets rel on the links of a document. Links to URLs the
+// policy rejects are left bare, so that they are reduced to their text.
+type linkTransformer struct{}
+
+func (linkTransformer) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		var dest []byte
+		switch link := n.(type) {
+		case *ast.Link:
+			dest = link.Destination
+		case *ast.AutoLink:
+			dest = link.URL(reader.Source())
+			if link.AutoLinkType == ast.AutoLinkEmail {
+				dest = append([]byte("mailto:"), dest...)
+			}
+		default:
+			return ast.WalkContinue, nil
+		}
+
+		if safeURL(dest) {
+			n.SetAttributeString("rel", []byte(linkRel))
+		}
+
+		return ast.WalkContinue, nil
+	})
+}
+
+func safeURL(dest []byte) bool {
+	u, err := url.Parse(string(dest))
+	if err != nil {
+		return false
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return true
+	case "":
+		// bare URLs like www.example.com, which are rendered as http links
+		return bytes.HasPrefix(dest, []byte("www."))
+	default:
+		return false
+	}
+}
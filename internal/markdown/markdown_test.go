@@ -0,0 +1,161 @@
+package markdown
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "emphasis",
+			source: "*hello* **world** ~~old~~",
+			want:   "<p><em>hello</em> <strong>world</strong> <del>old</del></p>\n",
+		},
+		{
+			name:   "links",
+			source: "[docs](https://example.com/docs)",
+			want:   `<p><a href="https://example.com/docs" rel="nofollow ugc">docs</a></p>` + "\n",
+		},
+		{
+			name:   "bare urls",
+			source: "see https://example.com",
+			want:   `<p>see <a href="https://example.com" rel="nofollow ugc">https://example.com</a></p>` + "\n",
+		},
+		{
+			name:   "raw html is left out",
+			source: "<script>alert(1)</script>",
+			want:   "\n",
+		},
+		{
+			name:   "unsafe links",
+			source: "[click](javascript:alert(1))",
+			want:   "<p>click</p>\n",
+		},
+		{
+			name:   "headings and images are reduced to text",
+			source: "# Title\n\n![alt](https://example.com/a.png)",
+			want:   "Title\n<p></p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %q. got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func FuzzSanitize(f *testing.F) {
+	for _, seed := range []string{
+		`<a href="https://example.com" rel="noopener">x</a>`,
+		`<a href="javascript:alert(1)">x</a>`,
+		`<img src=x onerror=alert(1)>`,
+		`<p style="color:red" onclick="alert(1)">x</p>`,
+		`<svg><script>alert(1)</script></svg>`,
+		`<a href="  JaVaScRiPt:alert(1)">x</a>`,
+		`<<a href=https://example.com>>`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		checkSafe(t, Sanitize(input))
+	})
+}
+
+func FuzzRender(f *testing.F) {
+	for _, seed := range []string{
+		"*a* [b](https://example.com) <b>c</b>",
+		"[x](javascript:alert(1))",
+		"<a href=\"javascript:alert(1)\">x</a>",
+		"```\n<script>alert(1)</script>\n```",
+		"www.example.com/<script>",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, source string) {
+		out, err := Render(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkSafe(t, out)
+	})
+}
+
+var allowedAttrs = map[string]map[string]bool{
+	"p": {}, "br": {}, "hr": {},
+	"em": {}, "strong": {}, "del": {},
+	"code": {}, "pre": {}, "blockquote": {},
+	"ul": {}, "li": {},
+	"ol": {"start": true},
+	"a":  {"href": true, "rel": true},
+}
+
+// checkSafe fails when out has an element or an attribute outside of the
+// allow-list, or a link that isn't nofollow or leads to an unsafe URL.
+func checkSafe(t *testing.T, out string) {
+	t.Helper()
+
+	z := html.NewTokenizer(strings.NewReader(out))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				t.Fatalf("unparseable output %q: %v", out, z.Err())
+			}
+			return
+		}
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := z.Token()
+		attrs, ok := allowedAttrs[token.Data]
+		if !ok {
+			t.Fatalf("element %q not allowed in %q", token.Data, out)
+		}
+
+		var nofollow bool
+		for _, attr := range token.Attr {
+			if !attrs[attr.Key] {
+				t.Fatalf("attribute %q not allowed on %q in %q", attr.Key, token.Data, out)
+			}
+
+			switch attr.Key {
+			case "href":
+				u, err := url.Parse(attr.Val)
+				if err != nil {
+					t.Fatalf("unparseable link %q in %q", attr.Val, out)
+				}
+				if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "mailto" {
+					t.Fatalf("unsafe link %q in %q", attr.Val, out)
+				}
+			case "rel":
+				nofollow = strings.Contains(attr.Val, "nofollow")
+			}
+		}
+
+		if token.Data == "a" && !nofollow {
+			t.Fatalf("link without nofollow in %q", out)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package notifications
+
+import "context"
+
+func NewMockService() Service {
+	return &MockService{}
+}
+
+type MockService struct{}
+
+func (m MockService) Notify(ctx context.Context, event Event) (bool, error) {
+	return true, nil
+}
+func (m MockService) List(ctx context.Context, userID int64, limit, offset int) ([]Notification, error) {
+	return []Notification{}, nil
+}
+func (m MockService) UnreadCount(ctx context.Context, userID int64) (int, error) {
+	return 0, nil
+}
+func (m MockService) MarkRead(ctx context.Context, userID int64, ids []int64) error {
+	return nil
+}
+func (m MockService) MarkAllRead(ctx context.Context, userID int64) error {
+	return nil
+}
+func (m MockService) GetPreferences(ctx context.Context, userID int64) ([]Preference, error) {
+	return []Preference{}, nil
+}
+func (m MockService) SetPreference(ctx context.Context, userID int64, t Type, enabled bool) error {
+	return nil
+}
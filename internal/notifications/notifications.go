@@ -0,0 +1,118 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type Type string
+
+const (
+	TypeFollow         Type = "follow"
+	TypeFollowRequest  Type = "follow_request"
+	TypeFollowAccepted Type = "follow_accepted"
+	TypeComment        Type = "comment"
+	TypeMention        Type = "mention"
+)
+
+// Types lists every notification type, in the order preferences are shown.
+var Types = []Type{
+	TypeFollow,
+	TypeFollowRequest,
+	TypeFollowAccepted,
+	TypeComment,
+	TypeMention,
+}
+
+var verbs = map[Type]string{
+	TypeFollow:         "followed you",
+	TypeFollowRequest:  "requested to follow you",
+	TypeFollowAccepted: "accepted your follow request",
+	TypeComment:        "commented on your post",
+	TypeMention:        "mentioned you",
+}
+
+func (t Type) Valid() bool {
+	_, ok := verbs[t]
+	return ok
+}
+
+// Event is something that happened to RecipientID because of ActorID. The
+// entity is what the event is about, e.g. the post that was commented on.
+type Event struct {
+	Type        Type   `json:"type"`
+	RecipientID int64  `json:"recipient_id"`
+	ActorID     int64  `json:"actor_id"`
+	EntityType  string `json:"entity_type"`
+	EntityID    int64  `json:"entity_id"`
+}
+
+// Notification groups the events of the same type about the same entity
+// which are unread, or were read together, so ten comments on a post show up
+// as a single notification until it is read, and the next ones start a new
+// group. ID is the id of the most recent event of the group.
+type Notification struct {
+	ID          int64    `json:"id"`
+	Type        Type     `json:"type"`
+	EntityType  string   `json:"entity_type"`
+	EntityID    int64    `json:"entity_id"`
+	Actors      []string `json:"actors"`
+	ActorsCount int      `json:"actors_count"`
+	Message     string   `json:"message"`
+	Read        bool     `json:"read"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+type Preference struct {
+	Type    Type `json:"type"`
+	Enabled bool `json:"enabled"`
+}
+
+// Service records events and serves them back to their recipients.
+type Service interface {
+	Notify(ctx context.Context, event Event) (bool, error)
+	List(ctx context.Context, userID int64, limit, offset int) ([]Notification, error)
+	UnreadCount(ctx context.Context, userID int64) (int, error)
+	MarkRead(ctx context.Context, userID int64, ids []int64) error
+	MarkAllRead(ctx context.Context, userID int64) error
+	GetPreferences(ctx context.Context, userID int64) ([]Preference, error)
+	SetPreference(ctx context.Context, userID int64, t Type, enabled bool) error
+}
+
+// message renders a group, e.g. "alice and 4 others commented on your post".
+func message(t Type, actors []string, count int) string {
+	if len(actors) == 0 {
+		return ""
+	}
+
+	switch {
+	case count <= 1:
+		return fmt.Sprintf("%s %s", actors[0], verbs[t])
+	case count == 2 && len(actors) >= 2:
+		return fmt.Sprintf("%s and %s %s", actors[0], actors[1], verbs[t])
+	case count == 2:
+		return fmt.Sprintf("%s and 1 other %s", actors[0], verbs[t])
+	default:
+		return fmt.Sprintf("%s and %d others %s", actors[0], count-1, verbs[t])
+	}
+}
+
+// distinct keeps the first max unique names, preserving order.
+func distinct(names []string, max int) []string {
+	seen := make(map[string]bool, len(names))
+	out := make([]string, 0, max)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+		if len(out) == max {
+			break
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,35 @@
+package notifications
+
+import "testing"
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		actors []string
+		count  int
+		want   string
+	}{
+		{[]string{"alice"}, 1, "alice commented on your post"},
+		{[]string{"alice", "bob"}, 2, "alice and bob commented on your post"},
+		{[]string{"alice", "bob", "carol"}, 5, "alice and 4 others commented on your post"},
+	}
+
+	for _, tt := range tests {
+		if got := message(TypeComment, tt.actors, tt.count); got != tt.want {
+			t.Errorf("expected %q. got %q", tt.want, got)
+		}
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	got := distinct([]string{"alice", "bob", "alice", "carol", "dave"}, 3)
+	want := []string{"alice", "bob", "carol"}
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %v. got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected %v. got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var QueryTimeoutDuration = time.Second * 5
+
+// maxActors is how many actor names are returned per grouped notification.
+const maxActors = 3
+
+type PostgresService struct {
+	db *sql.DB
+}
+
+func NewPostgresService(db *sql.DB) *PostgresService {
+	return &PostgresService{db: db}
+}
+
+// Notify stores an event unless the recipient caused it or turned this type
+// of notification off. It reports whether the notification was stored.
+func (s *PostgresService) Notify(ctx context.Context, event Event) (bool, error) {
+	if event.RecipientID == event.ActorID {
+		return false, nil
+	}
+
+	query := `
+  INSERT INTO notifications (user_id, actor_id, type, entity_type, entity_id)
+  SELECT $1, $2, $3, $4, $5
+  WHERE NOT EXISTS (
+    SELECT 1 FROM notification_preferences
+    WHERE user_id = $1 AND type = $3 AND NOT enabled
+  )
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(
+		ctx,
+		query,
+		event.RecipientID,
+		event.ActorID,
+		event.Type,
+		event.EntityType,
+		event.EntityID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// List returns the groups of notifications of a user, most recent first. The
+// unread events about an entity form one group, and each batch of them marked
+// read stays a group of its own, as they share their read_at.
+func (s *PostgresService) List(ctx context.Context, userID int64, limit, offset int) ([]Notification, error) {
+	query := `
+  SELECT
+    MAX(n.id),
+    n.type, n.entity_type, n.entity_id,
+    (ARRAY_AGG(u.username ORDER BY n.created_at DESC))[1:10],
+    COUNT(DISTINCT n.actor_id),
+    BOOL_AND(n.read_at IS NOT NULL),
+    MAX(n.created_at)
+  FROM notifications n
+  JOIN users u ON u.id = n.actor_id
+  WHERE n.user_id = $1
+  GROUP BY n.type, n.entity_type, n.entity_id, n.read_at
+  ORDER BY MAX(n.created_at) DESC
+  LIMIT $2 OFFSET $3
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		var actors []string
+		err := rows.Scan(
+			&n.ID,
+			&n.Type,
+			&n.EntityType,
+			&n.EntityID,
+			pq.Array(&actors),
+			&n.ActorsCount,
+			&n.Read,
+			&n.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		n.Actors = distinct(actors, maxActors)
+		n.Message = message(n.Type, n.Actors, n.ActorsCount)
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// UnreadCount counts grouped notifications with at least one unread event.
+func (s *PostgresService) UnreadCount(ctx context.Context, userID int64) (int, error) {
+	query := `
+  SELECT COUNT(DISTINCT (type, entity_type, entity_id))
+  FROM notifications
+  WHERE user_id = $1 AND read_at IS NULL
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// MarkRead marks the whole group of each given notification id as read.
+func (s *PostgresService) MarkRead(ctx context.Context, userID int64, ids []int64) error {
+	query := `
+  UPDATE notifications SET read_at = NOW()
+  WHERE user_id = $1 AND read_at IS NULL AND (type, entity_type, entity_id) IN (
+    SELECT type, entity_type, entity_id FROM notifications
+    WHERE user_id = $1 AND id = ANY($2)
+  )
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, pq.Array(ids))
+	return err
+}
+
+func (s *PostgresService) MarkAllRead(ctx context.Context, userID int64) error {
+	query := `UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// GetPreferences returns a preference for every type, enabled unless the user
+// turned it off.
+func (s *PostgresService) GetPreferences(ctx context.Context, userID int64) ([]Preference, error) {
+	query := `SELECT type, enabled FROM notification_preferences WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := map[Type]bool{}
+	for rows.Next() {
+		var t Type
+		var enabled bool
+		if err := rows.Scan(&t, &enabled); err != nil {
+			return nil, err
+		}
+		stored[t] = enabled
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	preferences := make([]Preference, 0, len(Types))
+	for _, t := range Types {
+		enabled, ok := stored[t]
+		preferences = append(preferences, Preference{Type: t, Enabled: !ok || enabled})
+	}
+
+	return preferences, nil
+}
+
+func (s *PostgresService) SetPreference(ctx context.Context, userID int64, t Type, enabled bool) error {
+	query := `
+  INSERT INTO notification_preferences (user_id, type, enabled) VALUES ($1, $2, $3)
+  ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, t, enabled)
+	return err
+}
@@ -5,42 +5,73 @@ import (
 	"time"
 )
 
+// FixedWindowRateLimiter allows limit requests per key in windows starting
+// with the first request of the key. A client can make up to twice the limit
+// in a short time around the end of a window.
 type FixedWindowRateLimiter struct {
-	sync.RWMutex
-	clients map[string]int
+	sync.Mutex
+	clients map[string]*fixedWindow
 	limit   int
 	window  time.Duration
+	now     func() time.Time
+	janitor *janitor
+}
+
+type fixedWindow struct {
+	start time.Time
+	count int
 }
 
 func NewFixedWindowRateLimiter(limit int, window time.Duration) *FixedWindowRateLimiter {
-	return &FixedWindowRateLimiter{
-		clients: make(map[string]int),
+	rl := &FixedWindowRateLimiter{
+		clients: make(map[string]*fixedWindow),
 		limit:   limit,
 		window:  window,
+		now:     time.Now,
 	}
+	rl.janitor = startJanitor(window, rl.sweep)
+
+	return rl
 }
 
-func (rl *FixedWindowRateLimiter) Allow(ip string) (bool, time.Duration) {
-	rl.RLock()
-	count, exists := rl.clients[ip]
-	rl.RUnlock()
+func (rl *FixedWindowRateLimiter) Allow(key string) Result {
+	if rl.limit <= 0 || rl.window <= 0 {
+		return refused(rl.limit, rl.window)
+	}
+
+	rl.Lock()
+	defer rl.Unlock()
 
-	if !exists || count < rl.limit {
-		rl.Lock()
-		if !exists {
-			go rl.resetCount(ip)
-		}
+	now := rl.now()
 
-		rl.clients[ip]++
-		rl.Unlock()
-		return true, 0
+	w, exists := rl.clients[key]
+	if !exists || !now.Before(w.start.Add(rl.window)) {
+		w = &fixedWindow{start: now}
+		rl.clients[key] = w
 	}
-	return false, rl.window
+
+	reset := w.start.Add(rl.window).Sub(now)
+	if w.count >= rl.limit {
+		return Result{Limit: rl.limit, Reset: reset, RetryAfter: reset}
+	}
+
+	w.count++
+	return Result{Allowed: true, Limit: rl.limit, Remaining: rl.limit - w.count, Reset: reset}
+}
+
+func (rl *FixedWindowRateLimiter) Stop() {
+	rl.janitor.Stop()
 }
 
-func (rl *FixedWindowRateLimiter) resetCount(ip string) {
-	time.Sleep(rl.window)
+// sweep forgets the keys whose window is over.
+func (rl *FixedWindowRateLimiter) sweep() {
 	rl.Lock()
-	delete(rl.clients, ip)
-	rl.Unlock()
+	defer rl.Unlock()
+
+	now := rl.now()
+	for key, w := range rl.clients {
+		if !now.Before(w.start.Add(rl.window)) {
+			delete(rl.clients, key)
+		}
+	}
 }
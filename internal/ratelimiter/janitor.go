@@ -0,0 +1,44 @@
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+)
+
+// minSweepInterval keeps limiters with tiny windows from sweeping constantly.
+const minSweepInterval = time.Second
+
+// janitor periodically removes the keys a limiter no longer needs, with a
+// single goroutine however many keys there are.
+type janitor struct {
+	stop chan struct{}
+	once sync.Once
+}
+
+func startJanitor(interval time.Duration, sweep func()) *janitor {
+	if interval < minSweepInterval {
+		interval = minSweepInterval
+	}
+
+	j := &janitor{stop: make(chan struct{})}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				sweep()
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+
+	return j
+}
+
+func (j *janitor) Stop() {
+	j.once.Do(func() { close(j.stop) })
+}
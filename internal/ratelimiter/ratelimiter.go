@@ -1,13 +1,117 @@
 package ratelimiter
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
+// Strategies a Limiter can be built with.
+const (
+	StrategyFixedWindow   = "fixed_window"
+	StrategySlidingWindow = "sliding_window"
+	StrategyTokenBucket   = "token_bucket"
+)
+
+// Limiter allows a number of requests per key over a time frame.
 type Limiter interface {
-	Allow(ip string) (bool, time.Duration)
+	Allow(key string) Result
+	// Stop releases the resources of the limiter, which must not be used
+	// afterwards.
+	Stop()
+}
+
+// Result is the outcome of a request, with the state of the allowance of its
+// key right after it.
+type Result struct {
+	Allowed bool
+	// Limit is the number of requests allowed per time frame.
+	Limit int
+	// Remaining is how many more requests would be allowed right away.
+	Remaining int
+	// Reset is how long until the allowance is whole again.
+	Reset time.Duration
+	// RetryAfter is how long to wait before the next request may be allowed,
+	// set when this one was refused.
+	RetryAfter time.Duration
+}
+
+// refused is the result of every request to a limiter that allows none.
+func refused(limit int, window time.Duration) Result {
+	return Result{Limit: limit, Reset: window, RetryAfter: window}
+}
+
+// Policy is a named allowance, stricter than the default one, for some
+// routes.
+type Policy struct {
+	RequestPerTimeFrame int           `yaml:"requests"`
+	TimeFrame           time.Duration `yaml:"time_frame"`
 }
 
 type Config struct {
-	RequestPerTimeFrame int
-	TimeFrame           time.Duration
-	Enabled             bool
+	RequestPerTimeFrame int           `yaml:"requests"`
+	TimeFrame           time.Duration `yaml:"time_frame"`
+	Enabled             bool          `yaml:"enabled"`
+	// Strategy is one of the Strategy constants, fixed window by default.
+	Strategy string `yaml:"strategy"`
+	// FailurePolicy is what the redis limiter does while redis is
+	// unreachable, one of the Failure constants, local limiting by default.
+	FailurePolicy string `yaml:"failure_policy"`
+	// Policies are applied on top of the default allowance, by name.
+	Policies map[string]Policy `yaml:"policies"`
+}
+
+// WithPolicy is cfg with the allowance of p instead of the default one.
+func (cfg Config) WithPolicy(p Policy) Config {
+	cfg.RequestPerTimeFrame = p.RequestPerTimeFrame
+	cfg.TimeFrame = p.TimeFrame
+	return cfg
+}
+
+// New builds the Limiter selected by cfg.
+func New(cfg Config) (Limiter, error) {
+	switch cfg.Strategy {
+	case "", StrategyFixedWindow:
+		return NewFixedWindowRateLimiter(cfg.RequestPerTimeFrame, cfg.TimeFrame), nil
+	case StrategySlidingWindow:
+		return NewSlidingWindowRateLimiter(cfg.RequestPerTimeFrame, cfg.TimeFrame), nil
+	case StrategyTokenBucket:
+		return NewTokenBucketRateLimiter(cfg.RequestPerTimeFrame, cfg.TimeFrame), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limiter strategy %q", cfg.Strategy)
+	}
+}
+
+// PolicyDefault is the name of the default allowance within Policies.
+const PolicyDefault = "default"
+
+// Policies holds a Limiter per policy name, the default allowance included.
+type Policies map[string]Limiter
+
+// NewPolicies builds with build a Limiter for the default allowance of cfg
+// and for each of its policies.
+func NewPolicies(cfg Config, build func(Config) (Limiter, error)) (Policies, error) {
+	policies := Policies{}
+
+	limiter, err := build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	policies[PolicyDefault] = limiter
+
+	for name, p := range cfg.Policies {
+		limiter, err := build(cfg.WithPolicy(p))
+		if err != nil {
+			policies.Stop()
+			return nil, fmt.Errorf("rate limit policy %q: %w", name, err)
+		}
+		policies[name] = limiter
+	}
+
+	return policies, nil
+}
+
+func (p Policies) Stop() {
+	for _, limiter := range p {
+		limiter.Stop()
+	}
 }
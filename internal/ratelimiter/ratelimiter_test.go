@@ -0,0 +1,165 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func allowN(t *testing.T, rl Limiter, key string, n int) {
+	t.Helper()
+
+	for i := range n {
+		if res := rl.Allow(key); !res.Allowed {
+			t.Fatalf("request %d of %d refused", i+1, n)
+		}
+	}
+}
+
+func expectRefused(t *testing.T, rl Limiter, key string, retryAfter time.Duration) {
+	t.Helper()
+
+	res := rl.Allow(key)
+	if res.Allowed {
+		t.Fatal("expected the request to be refused")
+	}
+
+	if res.RetryAfter != retryAfter {
+		t.Errorf("expected to retry after %v. got %v", retryAfter, res.RetryAfter)
+	}
+}
+
+func TestFixedWindow(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewFixedWindowRateLimiter(3, time.Minute)
+	rl.now = clock.Now
+	defer rl.Stop()
+
+	allowN(t, rl, "a", 3)
+	clock.Advance(20 * time.Second)
+	expectRefused(t, rl, "a", 40*time.Second)
+
+	// keys don't share their allowance
+	allowN(t, rl, "b", 3)
+
+	clock.Advance(40 * time.Second)
+	allowN(t, rl, "a", 3)
+
+	clock.Advance(time.Minute)
+	rl.sweep()
+	if len(rl.clients) != 0 {
+		t.Errorf("expected expired keys to be swept. got %d", len(rl.clients))
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewSlidingWindowRateLimiter(10, time.Minute)
+	rl.now = clock.Now
+	defer rl.Stop()
+
+	clock.Advance(59 * time.Second)
+	allowN(t, rl, "a", 10)
+
+	// a fixed window would allow another 10 right away
+	clock.Advance(2 * time.Second)
+	expectRefused(t, rl, "a", 5*time.Second)
+
+	clock.Advance(5*time.Second + time.Millisecond)
+	allowN(t, rl, "a", 1)
+	expectRefused(t, rl, "a", 6*time.Second-time.Millisecond)
+
+	// with the previous window over, only the current one counts
+	clock.Advance(time.Minute)
+	allowN(t, rl, "a", 9)
+
+	clock.Advance(2 * time.Minute)
+	rl.sweep()
+	if len(rl.clients) != 0 {
+		t.Errorf("expected idle keys to be swept. got %d", len(rl.clients))
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewTokenBucketRateLimiter(4, time.Minute)
+	rl.now = clock.Now
+	defer rl.Stop()
+
+	allowN(t, rl, "a", 4)
+	expectRefused(t, rl, "a", 15*time.Second)
+
+	clock.Advance(15 * time.Second)
+	allowN(t, rl, "a", 1)
+	expectRefused(t, rl, "a", 15*time.Second)
+
+	// tokens don't pile up past the limit
+	clock.Advance(time.Hour)
+	rl.sweep()
+	if len(rl.clients) != 0 {
+		t.Errorf("expected full buckets to be swept. got %d", len(rl.clients))
+	}
+
+	allowN(t, rl, "a", 4)
+	expectRefused(t, rl, "a", 15*time.Second)
+}
+
+func TestResult(t *testing.T) {
+	clock := newFakeClock()
+
+	fixed := NewFixedWindowRateLimiter(3, time.Minute)
+	fixed.now = clock.Now
+	defer fixed.Stop()
+
+	sliding := NewSlidingWindowRateLimiter(3, time.Minute)
+	sliding.now = clock.Now
+	defer sliding.Stop()
+
+	bucket := NewTokenBucketRateLimiter(3, time.Minute)
+	bucket.now = clock.Now
+	defer bucket.Stop()
+
+	tests := []struct {
+		name string
+		rl   Limiter
+		want Result
+	}{
+		{name: "fixed window", rl: fixed, want: Result{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Minute}},
+		{name: "sliding window", rl: sliding, want: Result{Allowed: true, Limit: 3, Remaining: 2, Reset: 2 * time.Minute}},
+		{name: "token bucket", rl: bucket, want: Result{Allowed: true, Limit: 3, Remaining: 2, Reset: 20 * time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rl.Allow("a"); got != tt.want {
+				t.Errorf("expected %+v. got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, strategy := range []string{"", StrategyFixedWindow, StrategySlidingWindow, StrategyTokenBucket} {
+		rl, err := New(Config{RequestPerTimeFrame: 1, TimeFrame: time.Second, Strategy: strategy})
+		if err != nil {
+			t.Fatalf("strategy %q: %v", strategy, err)
+		}
+		rl.Stop()
+	}
+
+	if _, err := New(Config{Strategy: "leaky_bucket"}); err == nil {
+		t.Error("expected an unknown strategy to be rejected")
+	}
+}
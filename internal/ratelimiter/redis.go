@@ -0,0 +1,240 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// What a RedisRateLimiter does while redis is unreachable.
+const (
+	// FailureLocal limits each instance on its own, as if redis wasn't used.
+	FailureLocal = "local"
+	// FailureOpen allows every request.
+	FailureOpen = "open"
+	// FailureClosed refuses every request.
+	FailureClosed = "closed"
+)
+
+const (
+	redisKeyPrefix = "ratelimit:"
+	// redisTimeout bounds how long a request waits on redis before the
+	// failure policy applies.
+	redisTimeout = 100 * time.Millisecond
+	// redisRetryInterval is how long redis is left alone after a failure, so
+	// that an outage doesn't slow every request down by redisTimeout.
+	redisRetryInterval = 5 * time.Second
+)
+
+// The scripts run atomically in redis, so instances never race on a key.
+// Times are in milliseconds and come from the callers, whose clocks are
+// expected to be in sync.
+var (
+	// KEYS[1] counter; ARGV limit, window
+	fixedWindowScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+local ttl = redis.call('PTTL', KEYS[1])
+-- a new counter, or one left without expiry, starts a window
+if ttl < 0 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+  ttl = tonumber(ARGV[2])
+end
+if count > tonumber(ARGV[1]) then
+  return {0, count, ttl}
+end
+return {1, count, ttl}
+`)
+
+	// KEYS[1] current window, KEYS[2] previous window; ARGV limit, window,
+	// time elapsed in the current window
+	slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local prev = tonumber(redis.call('GET', KEYS[2]) or '0')
+local curr = tonumber(redis.call('GET', KEYS[1]) or '0')
+if prev * (1 - tonumber(ARGV[3]) / window) + curr + 1 > limit then
+  return {0, prev, curr}
+end
+curr = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], window * 2)
+return {1, prev, curr}
+`)
+
+	// KEYS[1] bucket; ARGV limit, window, now. Tokens are returned as a
+	// string since redis truncates numbers returned by scripts.
+	tokenBucketScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(bucket[1]) or limit
+local last = tonumber(bucket[2]) or now
+tokens = math.min(limit, tokens + math.max(0, now - last) * limit / window)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', now)
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, tostring(tokens)}
+`)
+)
+
+// RedisRateLimiter shares the counts of every key between instances through
+// redis, with the same strategies as the local limiters.
+type RedisRateLimiter struct {
+	rdb      *redis.Client
+	limit    int
+	window   time.Duration
+	strategy string
+	failure  string
+	local    Limiter
+	now      func() time.Time
+	logger   *zap.SugaredLogger
+	// downUntil is when to try redis again after a failure, in unix
+	// nanoseconds.
+	downUntil atomic.Int64
+}
+
+func NewRedisRateLimiter(rdb *redis.Client, cfg Config, logger *zap.SugaredLogger) (*RedisRateLimiter, error) {
+	switch cfg.FailurePolicy {
+	case "", FailureLocal, FailureOpen, FailureClosed:
+	default:
+		return nil, fmt.Errorf("unknown rate limiter failure policy %q", cfg.FailurePolicy)
+	}
+
+	local, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	strategy := cfg.Strategy
+	if strategy == "" {
+		strategy = StrategyFixedWindow
+	}
+
+	failure := cfg.FailurePolicy
+	if failure == "" {
+		failure = FailureLocal
+	}
+
+	return &RedisRateLimiter{
+		rdb:      rdb,
+		limit:    cfg.RequestPerTimeFrame,
+		window:   cfg.TimeFrame,
+		strategy: strategy,
+		failure:  failure,
+		local:    local,
+		now:      time.Now,
+		logger:   logger,
+	}, nil
+}
+
+func (rl *RedisRateLimiter) Allow(key string) Result {
+	if rl.limit <= 0 || rl.window <= 0 {
+		return refused(rl.limit, rl.window)
+	}
+
+	now := rl.now()
+	if now.UnixNano() < rl.downUntil.Load() {
+		return rl.fail(key)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	res, err := rl.allow(ctx, key, now)
+	if err != nil {
+		if rl.downUntil.Swap(now.Add(redisRetryInterval).UnixNano()) == 0 {
+			rl.logger.Errorw("rate limiting without redis", "failure_policy", rl.failure, "error", err)
+		}
+		return rl.fail(key)
+	}
+
+	if rl.downUntil.Swap(0) != 0 {
+		rl.logger.Infow("rate limiting with redis again")
+	}
+
+	return res
+}
+
+func (rl *RedisRateLimiter) allow(ctx context.Context, key string, now time.Time) (Result, error) {
+	key = redisKeyPrefix + key
+	windowMs := rl.window.Milliseconds()
+
+	switch rl.strategy {
+	case StrategySlidingWindow:
+		start := now.Truncate(rl.window)
+		elapsed := now.Sub(start)
+		index := start.UnixMilli() / windowMs
+
+		res, err := slidingWindowScript.Run(
+			ctx, rl.rdb,
+			[]string{windowKey(key, index), windowKey(key, index-1)},
+			rl.limit, windowMs, elapsed.Milliseconds(),
+		).Int64Slice()
+		if err != nil {
+			return Result{}, err
+		}
+
+		return slidingResult(rl.limit, rl.window, elapsed, int(res[1]), int(res[2]), res[0] == 1), nil
+
+	case StrategyTokenBucket:
+		res, err := tokenBucketScript.Run(ctx, rl.rdb, []string{key}, rl.limit, windowMs, now.UnixMilli()).Slice()
+		if err != nil {
+			return Result{}, err
+		}
+
+		if len(res) != 2 {
+			return Result{}, errors.New("unexpected token bucket reply")
+		}
+
+		raw, _ := res[1].(string)
+		tokens, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return Result{}, err
+		}
+
+		allowed, _ := res[0].(int64)
+		return bucketResult(rl.limit, rl.window, tokens, allowed == 1), nil
+
+	default:
+		res, err := fixedWindowScript.Run(ctx, rl.rdb, []string{key}, rl.limit, windowMs).Int64Slice()
+		if err != nil {
+			return Result{}, err
+		}
+
+		reset := time.Duration(res[2]) * time.Millisecond
+		if res[0] != 1 {
+			return Result{Limit: rl.limit, Reset: reset, RetryAfter: reset}, nil
+		}
+		return Result{Allowed: true, Limit: rl.limit, Remaining: max(0, rl.limit-int(res[1])), Reset: reset}, nil
+	}
+}
+
+func windowKey(key string, index int64) string {
+	return key + ":" + strconv.FormatInt(index, 10)
+}
+
+// fail applies the failure policy to a request.
+func (rl *RedisRateLimiter) fail(key string) Result {
+	switch rl.failure {
+	case FailureOpen:
+		return Result{Allowed: true, Limit: rl.limit, Remaining: rl.limit}
+	case FailureClosed:
+		return Result{Limit: rl.limit, Reset: redisRetryInterval, RetryAfter: redisRetryInterval}
+	default:
+		return rl.local.Allow(key)
+	}
+}
+
+func (rl *RedisRateLimiter) Stop() {
+	rl.local.Stop()
+}
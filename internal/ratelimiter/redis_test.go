@@ -0,0 +1,135 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// newMiniredisLimiter returns a limiter backed by an in-memory redis, whose
+// clock is the fake one of the tests.
+func newMiniredisLimiter(t *testing.T, strategy string, limit int, clock *fakeClock) (*RedisRateLimiter, *miniredis.Miniredis) {
+	t.Helper()
+
+	m := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	rl, err := NewRedisRateLimiter(rdb, Config{RequestPerTimeFrame: limit, TimeFrame: time.Minute, Strategy: strategy, FailurePolicy: FailureClosed}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl.now = clock.Now
+	t.Cleanup(rl.Stop)
+
+	return rl, m
+}
+
+func TestRedisFixedWindow(t *testing.T) {
+	clock := newFakeClock()
+	rl, m := newMiniredisLimiter(t, StrategyFixedWindow, 3, clock)
+
+	allowN(t, rl, "a", 3)
+	// the window is kept by the expiry of the counter in redis
+	m.FastForward(20 * time.Second)
+	expectRefused(t, rl, "a", 40*time.Second)
+
+	// keys don't share their allowance
+	allowN(t, rl, "b", 3)
+
+	m.FastForward(40 * time.Second)
+	allowN(t, rl, "a", 3)
+
+	t.Run("should expire counters left without expiry", func(t *testing.T) {
+		if err := m.Set(redisKeyPrefix+"c", "3"); err != nil {
+			t.Fatal(err)
+		}
+
+		expectRefused(t, rl, "c", time.Minute)
+		if ttl := m.TTL(redisKeyPrefix + "c"); ttl != time.Minute {
+			t.Errorf("expected the counter to expire in a minute. got %v", ttl)
+		}
+	})
+}
+
+func TestRedisSlidingWindow(t *testing.T) {
+	clock := newFakeClock()
+	rl, _ := newMiniredisLimiter(t, StrategySlidingWindow, 10, clock)
+
+	clock.Advance(59 * time.Second)
+	allowN(t, rl, "a", 10)
+
+	// a fixed window would allow another 10 right away
+	clock.Advance(2 * time.Second)
+	expectRefused(t, rl, "a", 5*time.Second)
+
+	clock.Advance(5*time.Second + time.Millisecond)
+	allowN(t, rl, "a", 1)
+	expectRefused(t, rl, "a", 6*time.Second-time.Millisecond)
+
+	// with the previous window over, only the current one counts
+	clock.Advance(time.Minute)
+	allowN(t, rl, "a", 9)
+}
+
+func TestRedisTokenBucket(t *testing.T) {
+	clock := newFakeClock()
+	rl, _ := newMiniredisLimiter(t, StrategyTokenBucket, 4, clock)
+
+	allowN(t, rl, "a", 4)
+	expectRefused(t, rl, "a", 15*time.Second)
+
+	clock.Advance(15 * time.Second)
+	allowN(t, rl, "a", 1)
+	expectRefused(t, rl, "a", 15*time.Second)
+
+	// tokens don't pile up past the limit
+	clock.Advance(time.Hour)
+	allowN(t, rl, "a", 4)
+	expectRefused(t, rl, "a", 15*time.Second)
+}
+
+func TestRedisFailurePolicy(t *testing.T) {
+	// nothing listens on port 1, so every call to redis fails
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 10 * time.Millisecond, MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+
+	newLimiter := func(t *testing.T, policy string) *RedisRateLimiter {
+		rl, err := NewRedisRateLimiter(rdb, Config{RequestPerTimeFrame: 2, TimeFrame: time.Minute, FailurePolicy: policy}, zap.NewNop().Sugar())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(rl.Stop)
+		return rl
+	}
+
+	t.Run("local", func(t *testing.T) {
+		rl := newLimiter(t, FailureLocal)
+
+		allowN(t, rl, "a", 2)
+		if res := rl.Allow("a"); res.Allowed {
+			t.Error("expected the local limiter to refuse the request")
+		}
+	})
+
+	t.Run("open", func(t *testing.T) {
+		rl := newLimiter(t, FailureOpen)
+
+		allowN(t, rl, "a", 5)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		rl := newLimiter(t, FailureClosed)
+
+		expectRefused(t, rl, "a", redisRetryInterval)
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		if _, err := NewRedisRateLimiter(rdb, Config{FailurePolicy: "maybe"}, zap.NewNop().Sugar()); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package ratelimiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// SlidingWindowRateLimiter allows limit requests per key over any window,
+// not only over fixed ones. It estimates the count of the sliding window from
+// the counts of the current and previous fixed windows, weighing the previous
+// one by how much of it the sliding window still overlaps.
+type SlidingWindowRateLimiter struct {
+	sync.Mutex
+	clients map[string]*slidingWindow
+	limit   int
+	window  time.Duration
+	now     func() time.Time
+	janitor *janitor
+}
+
+type slidingWindow struct {
+	start time.Time
+	prev  int
+	curr  int
+}
+
+func NewSlidingWindowRateLimiter(limit int, window time.Duration) *SlidingWindowRateLimiter {
+	rl := &SlidingWindowRateLimiter{
+		clients: make(map[string]*slidingWindow),
+		limit:   limit,
+		window:  window,
+		now:     time.Now,
+	}
+	rl.janitor = startJanitor(window, rl.sweep)
+
+	return rl
+}
+
+func (rl *SlidingWindowRateLimiter) Allow(key string) Result {
+	if rl.limit <= 0 || rl.window <= 0 {
+		return refused(rl.limit, rl.window)
+	}
+
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := rl.now()
+	start := now.Truncate(rl.window)
+
+	w, exists := rl.clients[key]
+	if !exists {
+		w = &slidingWindow{start: start}
+		rl.clients[key] = w
+	}
+
+	if !w.start.Equal(start) {
+		if start.Sub(w.start) == rl.window {
+			w.prev = w.curr
+		} else {
+			w.prev = 0
+		}
+		w.curr = 0
+		w.start = start
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(rl.window)
+
+	if float64(w.prev)*weight+float64(w.curr)+1 > float64(rl.limit) {
+		return slidingResult(rl.limit, rl.window, elapsed, w.prev, w.curr, false)
+	}
+
+	w.curr++
+	return slidingResult(rl.limit, rl.window, elapsed, w.prev, w.curr, true)
+}
+
+// slidingResult is the result of a request elapsed into the current window,
+// given the counts of the previous and current windows after it.
+func slidingResult(limit int, window, elapsed time.Duration, prev, curr int, allowed bool) Result {
+	weight := 1 - float64(elapsed)/float64(window)
+	estimate := float64(prev)*weight + float64(curr)
+
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: max(0, int(math.Floor(float64(limit)-estimate))),
+	}
+
+	// the counts stop weighing once their window is out of the sliding one
+	switch {
+	case curr > 0:
+		res.Reset = 2*window - elapsed
+	case prev > 0:
+		res.Reset = window - elapsed
+	}
+
+	if !allowed {
+		res.RetryAfter = slidingRetryAfter(limit, window, elapsed, prev, curr)
+	}
+
+	return res
+}
+
+// slidingRetryAfter is how long after elapsed into the current window the
+// estimate falls low enough for another request, given the counts of the
+// previous and current windows.
+func slidingRetryAfter(limit int, window, elapsed time.Duration, prev, curr int) time.Duration {
+	w := float64(window)
+	room := float64(limit - curr - 1)
+
+	// the previous window may weigh little enough before this one ends
+	if room >= 0 && prev > 0 {
+		at := time.Duration(math.Ceil(w * (1 - room/float64(prev))))
+		if at < window {
+			return at - elapsed
+		}
+	}
+
+	// otherwise this window becomes the previous one and has to fade
+	wait := window - elapsed
+	if curr > 0 {
+		if at := time.Duration(math.Ceil(w * (1 - float64(limit-1)/float64(curr)))); at > 0 {
+			wait += at
+		}
+	}
+
+	return wait
+}
+
+func (rl *SlidingWindowRateLimiter) Stop() {
+	rl.janitor.Stop()
+}
+
+// sweep forgets the keys with no requests in the current or previous window.
+func (rl *SlidingWindowRateLimiter) sweep() {
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := rl.now()
+	for key, w := range rl.clients {
+		if !now.Before(w.start.Add(2 * rl.window)) {
+			delete(rl.clients, key)
+		}
+	}
+}
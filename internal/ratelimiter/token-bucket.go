@@ -0,0 +1,116 @@
+package ratelimiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucketRateLimiter gives each key a bucket of limit tokens, refilled
+// steadily over window. Every request takes a token, so bursts up to limit are
+// allowed and the sustained rate is limit per window.
+type TokenBucketRateLimiter struct {
+	sync.Mutex
+	clients map[string]*bucket
+	limit   int
+	window  time.Duration
+	now     func() time.Time
+	janitor *janitor
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucketRateLimiter(limit int, window time.Duration) *TokenBucketRateLimiter {
+	rl := &TokenBucketRateLimiter{
+		clients: make(map[string]*bucket),
+		limit:   limit,
+		window:  window,
+		now:     time.Now,
+	}
+	rl.janitor = startJanitor(window, rl.sweep)
+
+	return rl
+}
+
+func (rl *TokenBucketRateLimiter) Allow(key string) Result {
+	if rl.limit <= 0 || rl.window <= 0 {
+		return refused(rl.limit, rl.window)
+	}
+
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := rl.now()
+
+	b, exists := rl.clients[key]
+	if !exists {
+		b = &bucket{tokens: float64(rl.limit), last: now}
+		rl.clients[key] = b
+	}
+
+	b.tokens = rl.refilled(b, now)
+	b.last = now
+
+	if b.tokens < 1 {
+		return bucketResult(rl.limit, rl.window, b.tokens, false)
+	}
+
+	b.tokens--
+	return bucketResult(rl.limit, rl.window, b.tokens, true)
+}
+
+// rate is the number of tokens added per nanosecond.
+func (rl *TokenBucketRateLimiter) rate() float64 {
+	return bucketRate(rl.limit, rl.window)
+}
+
+func bucketRate(limit int, window time.Duration) float64 {
+	return float64(limit) / float64(window)
+}
+
+// bucketResult is the result of a request given the tokens left after it.
+func bucketResult(limit int, window time.Duration, tokens float64, allowed bool) Result {
+	rate := bucketRate(limit, window)
+
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: int(tokens),
+		Reset:     time.Duration(math.Ceil((float64(limit) - tokens) / rate)),
+	}
+
+	if !allowed {
+		res.RetryAfter = time.Duration(math.Ceil((1 - tokens) / rate))
+	}
+
+	return res
+}
+
+func (rl *TokenBucketRateLimiter) refilled(b *bucket, now time.Time) float64 {
+	return math.Min(float64(rl.limit), b.tokens+float64(now.Sub(b.last))*rl.rate())
+}
+
+func (rl *TokenBucketRateLimiter) Stop() {
+	rl.janitor.Stop()
+}
+
+// sweep forgets the keys whose bucket is full again, which is how a new
+// bucket starts.
+func (rl *TokenBucketRateLimiter) sweep() {
+	if rl.limit <= 0 || rl.window <= 0 {
+		return
+	}
+
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := rl.now()
+	for key, b := range rl.clients {
+		if rl.refilled(b, now) >= float64(rl.limit) {
+			delete(rl.clients, key)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type BlockStore struct {
+	db *sql.DB
+}
+
+// Block records that blockerID blocked blockedID and drops every follow
+// relationship and pending follow request between the two, in both
+// directions.
+func (s *BlockStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+    INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2)
+    ON CONFLICT DO NOTHING
+    `
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			return err
+		}
+
+		query = `
+    DELETE FROM followers
+    WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+    `
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			return err
+		}
+
+		query = `
+    DELETE FROM follow_requests
+    WHERE (user_id = $1 AND requester_id = $2) OR (user_id = $2 AND requester_id = $1)
+    `
+		_, err := tx.ExecContext(ctx, query, blockerID, blockedID)
+		return err
+	})
+}
+
+func (s *BlockStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	query := `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, blockerID, blockedID)
+	return err
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func (s *BlockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	query := `
+  SELECT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+  )
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var blocked bool
+	if err := s.db.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked); err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
+
+func (s *BlockStore) GetBlocked(ctx context.Context, userID int64) ([]User, error) {
+	query := `
+  SELECT u.id, u.username
+  FROM user_blocks b
+  JOIN users u ON u.id = b.blocked_id
+  WHERE b.blocker_id = $1
+  ORDER BY b.created_at DESC
+  `
+
+	return queryUserList(ctx, s.db, query, userID)
+}
+
+type MuteStore struct {
+	db *sql.DB
+}
+
+func (s *MuteStore) Mute(ctx context.Context, muterID, mutedID int64) error {
+	query := `
+  INSERT INTO user_mutes (muter_id, muted_id) VALUES ($1, $2)
+  ON CONFLICT DO NOTHING
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+	return err
+}
+
+func (s *MuteStore) Unmute(ctx context.Context, muterID, mutedID int64) error {
+	query := `DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+	return err
+}
+
+func (s *MuteStore) GetMuted(ctx context.Context, userID int64) ([]User, error) {
+	query := `
+  SELECT u.id, u.username
+  FROM user_mutes m
+  JOIN users u ON u.id = m.muted_id
+  WHERE m.muter_id = $1
+  ORDER BY m.created_at DESC
+  `
+
+	return queryUserList(ctx, s.db, query, userID)
+}
+
+// queryUserList runs a query selecting (id, username) pairs.
+func queryUserList(ctx context.Context, db *sql.DB, query string, args ...any) ([]User, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
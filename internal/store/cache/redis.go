@@ -1,11 +1,17 @@
 package cache
 
-import "github.com/go-redis/redis/v8"
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/shanisharrma/gopher-social/internal/tracing"
+)
 
 func NewRedisClient(addr, pw string, db int) *redis.Client {
-	return redis.NewClient(&redis.Options{
+	rdb := redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: pw,
 		DB:       db,
 	})
+	rdb.AddHook(tracing.RedisHook{})
+
+	return rdb
 }
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/shanisharrma/gopher-social/internal/metrics"
 	"github.com/shanisharrma/gopher-social/internal/store"
 )
 
@@ -16,33 +17,50 @@ type UserStore struct {
 	rdb *redis.Client
 }
 
+// cachedUser adds to a user what it doesn't show in JSON but is cached with
+// it.
+type cachedUser struct {
+	*store.User
+	Suspension *store.Suspension `json:"suspension,omitempty"`
+}
+
 func (s *UserStore) Get(ctx context.Context, userID int64) (*store.User, error) {
 	cacheKey := fmt.Sprintf("user-%v", userID)
 
 	data, err := s.rdb.Get(ctx, cacheKey).Result()
 	if err == redis.Nil {
+		metrics.CacheRequests.WithLabelValues("user", "miss").Inc()
 		return nil, nil
 	} else if err != nil {
+		metrics.CacheRequests.WithLabelValues("user", "error").Inc()
 		return nil, err
 	}
+	metrics.CacheRequests.WithLabelValues("user", "hit").Inc()
 
-	var user store.User
+	user := cachedUser{User: &store.User{}}
 	if data != "" {
 		if err := json.Unmarshal([]byte(data), &user); err != nil {
 			return nil, err
 		}
 	}
+	user.User.Suspension = user.Suspension
 
-	return &user, nil
+	return user.User, nil
 }
 
 func (s *UserStore) Set(ctx context.Context, user *store.User) error {
 	cacheKey := fmt.Sprintf("user-%v", user.ID)
 
-	json, err := json.Marshal(user)
+	json, err := json.Marshal(cachedUser{User: user, Suspension: user.Suspension})
 	if err != nil {
 		return err
 	}
 
 	return s.rdb.SetEX(ctx, cacheKey, json, UserExpTime).Err()
 }
+
+func (s *UserStore) Delete(ctx context.Context, userID int64) error {
+	cacheKey := fmt.Sprintf("user-%v", userID)
+
+	return s.rdb.Del(ctx, cacheKey).Err()
+}
@@ -3,6 +3,10 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+
+	"github.com/shanisharrma/gopher-social/internal/audit"
+	"github.com/shanisharrma/gopher-social/internal/entities"
 )
 
 type Comment struct {
@@ -12,23 +16,34 @@ type Comment struct {
 	Content   string `json:"content"`
 	CreatedAt string `json:"created-at"`
 	User      User   `json:"user"`
+	// Entities are the mentions and hashtags of Content.
+	Entities []entities.Entity `json:"entities"`
+	// MentionedIDs are the users mentioned by the comment, set by Create.
+	MentionedIDs []int64 `json:"-"`
 }
 
 type CommentStore struct {
-	db *sql.DB
+	db    *sql.DB
+	audit audit.Recorder
 }
 
-func (s *CommentStore) GetByPostId(ctx context.Context, postID int64) ([]Comment, error) {
+// GetByPostId returns the comments of a post as seen by viewerID, leaving out
+// hidden comments and comments written by users who blocked, or were blocked
+// by, the viewer.
+func (s *CommentStore) GetByPostId(ctx context.Context, postID, viewerID int64) ([]Comment, error) {
 	query := `
   SELECT c.id,c.post_id, c.user_id, c.content,c.created_at, u.username, u.email, u.id FROM comments AS c
   JOIN users AS u ON u.id = c.user_id 
-  WHERE c.post_id = $1
+  WHERE c.post_id = $1 AND NOT c.is_hidden AND NOT EXISTS (
+    SELECT 1 FROM user_blocks b
+    WHERE (b.blocker_id = $2 AND b.blocked_id = c.user_id) OR (b.blocker_id = c.user_id AND b.blocked_id = $2)
+  )
   ORDER BY c.created_at DESC;
   `
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -51,25 +66,108 @@ func (s *CommentStore) GetByPostId(ctx context.Context, postID int64) ([]Comment
 		if err != nil {
 			return nil, err
 		}
+		c.Entities = entities.Parse(c.Content)
 		comments = append(comments, c)
 	}
 
 	return comments, nil
 }
 
+// Create saves a comment along with its hashtags and mentions.
 func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
+	comment.Entities = entities.Parse(comment.Content)
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+    INSERT INTO comments (user_id, post_id, content)
+    VALUES ($1, $2,$3)
+    RETURNING id, created_at;
+    `
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, &comment.UserID, &comment.PostID, &comment.Content).
+			Scan(&comment.ID, &comment.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		hashtags := entities.Values(comment.Entities, entities.TypeHashtag)
+		if err := saveHashtags(ctx, tx, commentEntities, comment.ID, hashtags); err != nil {
+			return err
+		}
+
+		usernames := entities.Values(comment.Entities, entities.TypeMention)
+		mentioned, err := saveMentions(ctx, tx, commentEntities, comment.ID, comment.UserID, usernames)
+		if err != nil {
+			return err
+		}
+
+		comment.MentionedIDs = mentioned
+		return nil
+	})
+}
+
+func (s *CommentStore) GetById(ctx context.Context, id int64) (*Comment, error) {
 	query := `
-  INSERT INTO comments (user_id, post_id, content)
-  VALUES ($1, $2,$3)
-  RETURNING id, created_at;
+  SELECT id, post_id, user_id, content, created_at
+  FROM comments
+  WHERE id = $1
   `
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(ctx, query, &comment.UserID, &comment.PostID, &comment.Content).
-		Scan(&comment.ID, &comment.CreatedAt)
+	var c Comment
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.CreatedAt)
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
 	}
-	return nil
+
+	c.Entities = entities.Parse(c.Content)
+	return &c, nil
+}
+
+// Delete removes a comment. Deleting someone else's comment through a role is
+// recorded in the audit log; the author of the post removing comments from it
+// is not.
+func (s *CommentStore) Delete(ctx context.Context, id int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+    DELETE FROM comments
+    WHERE id = $1
+    RETURNING id, post_id, user_id, content, created_at, (SELECT user_id FROM posts WHERE id = comments.post_id)
+    `
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var before Comment
+		var postAuthorID int64
+		err := tx.QueryRowContext(ctx, query, id).Scan(
+			&before.ID,
+			&before.PostID,
+			&before.UserID,
+			&before.Content,
+			&before.CreatedAt,
+			&postAuthorID,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if isPrivileged(ctx, before.UserID) && isPrivileged(ctx, postAuthorID) {
+			return recordAudit(ctx, tx, s.audit, "comment.delete", "comment", id, before, nil)
+		}
+
+		return nil
+	})
 }
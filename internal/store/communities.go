@@ -0,0 +1,421 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+
+	"github.com/lib/pq"
+	"github.com/shanisharrma/gopher-social/internal/audit"
+)
+
+const (
+	CommunityPublic     = "public"
+	CommunityInviteOnly = "invite_only"
+)
+
+// Community roles, from the least to the most powerful. They only grant
+// powers within their community.
+const (
+	CommunityRoleMember    = "member"
+	CommunityRoleModerator = "moderator"
+	CommunityRoleOwner     = "owner"
+)
+
+var communityRoleLevels = map[string]int{
+	CommunityRoleMember:    1,
+	CommunityRoleModerator: 2,
+	CommunityRoleOwner:     3,
+}
+
+// CommunityRoleAtLeast reports whether role grants the powers of minRole. An
+// empty role, for non members, grants none.
+func CommunityRoleAtLeast(role, minRole string) bool {
+	return role != "" && communityRoleLevels[role] >= communityRoleLevels[minRole]
+}
+
+var ErrNotInvited = errors.New("an invitation is required to join this community")
+
+type Community struct {
+	ID          int64  `json:"id"`
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Visibility  string `json:"visibility"`
+	OwnerID     int64  `json:"owner_id"`
+	MemberCount int    `json:"member_count"`
+	// ViewerRole is the role of the user the community was fetched for, empty
+	// when they aren't a member.
+	ViewerRole string `json:"viewer_role,omitempty"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type CommunityMember struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	JoinedAt string `json:"joined_at"`
+}
+
+type CommunityMemberPage struct {
+	Members    []CommunityMember `json:"members"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+type CommunityStore struct {
+	db    *sql.DB
+	audit audit.Recorder
+}
+
+// Create saves a community and makes its owner the first member.
+func (s *CommunityStore) Create(ctx context.Context, community *Community) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+    INSERT INTO communities (slug, name, description, visibility, owner_id)
+    VALUES ($1, $2, $3, $4, $5)
+    RETURNING id, created_at
+    `
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			community.Slug,
+			community.Name,
+			community.Description,
+			community.Visibility,
+			community.OwnerID,
+		).Scan(&community.ID, &community.CreatedAt)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		query = `INSERT INTO community_members (community_id, user_id, role) VALUES ($1, $2, $3)`
+		if _, err := tx.ExecContext(ctx, query, community.ID, community.OwnerID, CommunityRoleOwner); err != nil {
+			return err
+		}
+
+		community.MemberCount = 1
+		community.ViewerRole = CommunityRoleOwner
+		return nil
+	})
+}
+
+// GetById returns a community with the role of viewerID in it.
+func (s *CommunityStore) GetById(ctx context.Context, id, viewerID int64) (*Community, error) {
+	query := `
+  SELECT
+    c.id, c.slug, c.name, c.description, c.visibility, c.owner_id, c.created_at,
+    (SELECT COUNT(*) FROM community_members cm WHERE cm.community_id = c.id),
+    COALESCE((SELECT cm.role FROM community_members cm WHERE cm.community_id = c.id AND cm.user_id = $2), '')
+  FROM communities c
+  WHERE c.id = $1
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var c Community
+	err := s.db.QueryRowContext(ctx, query, id, viewerID).Scan(
+		&c.ID,
+		&c.Slug,
+		&c.Name,
+		&c.Description,
+		&c.Visibility,
+		&c.OwnerID,
+		&c.CreatedAt,
+		&c.MemberCount,
+		&c.ViewerRole,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+// GetRole returns the role of a user in a community, or an empty role when
+// they aren't a member.
+func (s *CommunityStore) GetRole(ctx context.Context, communityID, userID int64) (string, error) {
+	query := `SELECT role FROM community_members WHERE community_id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var role string
+	err := s.db.QueryRowContext(ctx, query, communityID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return role, err
+}
+
+// Join adds a member, using up their invitation if they have one. Joining
+// an invite only community without one fails with ErrNotInvited.
+func (s *CommunityStore) Join(ctx context.Context, community *Community, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `DELETE FROM community_invitations WHERE community_id = $1 AND user_id = $2`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, community.ID, userID)
+		if err != nil {
+			return err
+		}
+
+		invited, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if community.Visibility == CommunityInviteOnly && invited == 0 {
+			return ErrNotInvited
+		}
+
+		query = `
+    INSERT INTO community_members (community_id, user_id, role) VALUES ($1, $2, $3)
+    ON CONFLICT DO NOTHING
+    `
+		_, err = tx.ExecContext(ctx, query, community.ID, userID, CommunityRoleMember)
+		return err
+	})
+}
+
+// Leave removes a member. Owners can't leave their community.
+func (s *CommunityStore) Leave(ctx context.Context, communityID, userID int64) error {
+	query := `DELETE FROM community_members WHERE community_id = $1 AND user_id = $2 AND role <> $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, communityID, userID, CommunityRoleOwner)
+	return err
+}
+
+// Invite lets a user join an invite only community. Inviting a member fails
+// with ErrConflict.
+func (s *CommunityStore) Invite(ctx context.Context, communityID, userID, invitedBy int64) error {
+	query := `
+  INSERT INTO community_invitations (community_id, user_id, invited_by)
+  SELECT $1, $2, $3
+  WHERE NOT EXISTS (SELECT 1 FROM community_members WHERE community_id = $1 AND user_id = $2)
+  ON CONFLICT DO NOTHING
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, communityID, userID, invitedBy)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrConflict
+	}
+
+	return nil
+}
+
+// GetInvitations returns the communities a user is invited to.
+func (s *CommunityStore) GetInvitations(ctx context.Context, userID int64) ([]Community, error) {
+	query := `
+  SELECT
+    c.id, c.slug, c.name, c.description, c.visibility, c.owner_id, c.created_at,
+    (SELECT COUNT(*) FROM community_members cm WHERE cm.community_id = c.id),
+    ''
+  FROM community_invitations ci
+  JOIN communities c ON c.id = ci.community_id
+  WHERE ci.user_id = $1
+  ORDER BY ci.created_at DESC
+  `
+	return s.queryCommunities(ctx, query, userID)
+}
+
+// GetForUser returns the communities userID belongs to that viewerID may know
+// about: public ones, and invite only ones they share.
+func (s *CommunityStore) GetForUser(ctx context.Context, userID, viewerID int64) ([]Community, error) {
+	query := `
+  SELECT
+    c.id, c.slug, c.name, c.description, c.visibility, c.owner_id, c.created_at,
+    (SELECT COUNT(*) FROM community_members m WHERE m.community_id = c.id),
+    cm.role
+  FROM community_members cm
+  JOIN communities c ON c.id = cm.community_id
+  WHERE cm.user_id = $1 AND (
+    c.visibility = 'public' OR
+    EXISTS (SELECT 1 FROM community_members v WHERE v.community_id = c.id AND v.user_id = $2)
+  )
+  ORDER BY cm.joined_at DESC
+  `
+	return s.queryCommunities(ctx, query, userID, viewerID)
+}
+
+func (s *CommunityStore) queryCommunities(ctx context.Context, query string, args ...any) ([]Community, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	communities := []Community{}
+	for rows.Next() {
+		var c Community
+		err := rows.Scan(
+			&c.ID,
+			&c.Slug,
+			&c.Name,
+			&c.Description,
+			&c.Visibility,
+			&c.OwnerID,
+			&c.CreatedAt,
+			&c.MemberCount,
+			&c.ViewerRole,
+		)
+		if err != nil {
+			return nil, err
+		}
+		communities = append(communities, c)
+	}
+
+	return communities, rows.Err()
+}
+
+// GetMembers returns the members of a community, the most recent first.
+func (s *CommunityStore) GetMembers(ctx context.Context, communityID int64, q CursorQuery) (*CommunityMemberPage, error) {
+	beforeJoined, beforeID := int64(math.MaxInt64), int64(math.MaxInt64)
+	if q.Cursor != "" {
+		keys, err := DecodeCursor(q.Cursor, 2)
+		if err != nil {
+			return nil, err
+		}
+		beforeJoined, beforeID = keys[0], keys[1]
+	}
+
+	query := `
+  SELECT u.id, u.username, cm.role, cm.joined_at, EXTRACT(EPOCH FROM cm.joined_at)::bigint
+  FROM community_members cm
+  JOIN users u ON u.id = cm.user_id
+  WHERE cm.community_id = $1 AND (EXTRACT(EPOCH FROM cm.joined_at)::bigint, u.id) < ($2, $3)
+  ORDER BY cm.joined_at DESC, u.id DESC
+  LIMIT $4
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, communityID, beforeJoined, beforeID, q.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []CommunityMember{}
+	joined := []int64{}
+	for rows.Next() {
+		var m CommunityMember
+		var joinedAt int64
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Role, &m.JoinedAt, &joinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+		joined = append(joined, joinedAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &CommunityMemberPage{Members: members}
+	if len(members) > q.Limit {
+		page.Members = members[:q.Limit]
+		page.NextCursor = EncodeCursor(joined[q.Limit-1], page.Members[q.Limit-1].UserID)
+	}
+
+	return page, nil
+}
+
+// SetRole makes a member a moderator or a plain member again. It is recorded
+// in the audit log.
+func (s *CommunityStore) SetRole(ctx context.Context, communityID, userID int64, role string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+    UPDATE community_members cm SET role = $3
+    FROM (
+      SELECT role FROM community_members
+      WHERE community_id = $1 AND user_id = $2
+      FOR UPDATE
+    ) old
+    WHERE cm.community_id = $1 AND cm.user_id = $2 AND old.role <> $4
+    RETURNING old.role
+    `
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var before string
+		err := tx.QueryRowContext(ctx, query, communityID, userID, role, CommunityRoleOwner).Scan(&before)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		return recordAudit(
+			ctx, tx, s.audit,
+			"community.role_change", "user", userID,
+			map[string]any{"community_id": communityID, "role": before},
+			map[string]any{"community_id": communityID, "role": role},
+		)
+	})
+}
+
+// RemoveMember takes a member out of a community. It is recorded in the audit
+// log.
+func (s *CommunityStore) RemoveMember(ctx context.Context, communityID, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+    DELETE FROM community_members
+    WHERE community_id = $1 AND user_id = $2 AND role <> $3
+    RETURNING role
+    `
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var role string
+		err := tx.QueryRowContext(ctx, query, communityID, userID, CommunityRoleOwner).Scan(&role)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		return recordAudit(
+			ctx, tx, s.audit,
+			"community.remove_member", "user", userID,
+			map[string]any{"community_id": communityID, "role": role},
+			nil,
+		)
+	})
+}
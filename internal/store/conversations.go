@@ -0,0 +1,418 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/lib/pq"
+)
+
+// MaxConversationMembers bounds group conversations, their creator included.
+const MaxConversationMembers = 10
+
+type Conversation struct {
+	ID          int64                `json:"id"`
+	IsGroup     bool                 `json:"is_group"`
+	Title       string               `json:"title"`
+	Members     []ConversationMember `json:"members"`
+	LastMessage *Message             `json:"last_message"`
+	UnreadCount int                  `json:"unread_count"`
+	CreatedAt   string               `json:"created_at"`
+}
+
+// ConversationMember is a participant of a conversation. LastReadMessageID
+// lets the others show how far they have read.
+type ConversationMember struct {
+	UserID            int64  `json:"user_id"`
+	Username          string `json:"username"`
+	LastReadMessageID int64  `json:"last_read_message_id"`
+}
+
+type Message struct {
+	ID             int64  `json:"id"`
+	ConversationID int64  `json:"conversation_id"`
+	SenderID       int64  `json:"sender_id"`
+	Content        string `json:"content"`
+	CreatedAt      string `json:"created_at"`
+}
+
+type ConversationPage struct {
+	Conversations []Conversation `json:"conversations"`
+	NextCursor    string         `json:"next_cursor,omitempty"`
+}
+
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+type ConversationStore struct {
+	db *sql.DB
+}
+
+// GetOrCreateDirect returns the one-to-one conversation of two users, and
+// whether it had to be created.
+func (s *ConversationStore) GetOrCreateDirect(ctx context.Context, userID, otherID int64) (*Conversation, bool, error) {
+	key := fmt.Sprintf("%d:%d", min(userID, otherID), max(userID, otherID))
+	var id int64
+	created := false
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+    INSERT INTO conversations (is_group, direct_key, created_by)
+    VALUES (false, $1, $2)
+    ON CONFLICT (direct_key) DO NOTHING
+    RETURNING id
+    `
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, key, userID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			query = `SELECT id FROM conversations WHERE direct_key = $1`
+			return tx.QueryRowContext(ctx, query, key).Scan(&id)
+		}
+		if err != nil {
+			return err
+		}
+
+		created = true
+		return addMembers(ctx, tx, id, []int64{userID, otherID})
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	conversation, err := s.GetForMember(ctx, id, userID)
+	return conversation, created, err
+}
+
+// CreateGroup creates a group conversation between its creator and members.
+func (s *ConversationStore) CreateGroup(ctx context.Context, conversation *Conversation, creatorID int64, memberIDs []int64) error {
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+    INSERT INTO conversations (is_group, title, created_by)
+    VALUES (true, $1, $2)
+    RETURNING id
+    `
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if err := tx.QueryRowContext(ctx, query, conversation.Title, creatorID).Scan(&conversation.ID); err != nil {
+			return err
+		}
+
+		return addMembers(ctx, tx, conversation.ID, append([]int64{creatorID}, memberIDs...))
+	})
+	if err != nil {
+		return err
+	}
+
+	created, err := s.GetForMember(ctx, conversation.ID, creatorID)
+	if err != nil {
+		return err
+	}
+
+	*conversation = *created
+	return nil
+}
+
+func addMembers(ctx context.Context, tx *sql.Tx, conversationID int64, userIDs []int64) error {
+	query := `
+  INSERT INTO conversation_members (conversation_id, user_id)
+  SELECT $1, unnest($2::bigint[])
+  ON CONFLICT DO NOTHING
+  `
+	_, err := tx.ExecContext(ctx, query, conversationID, pq.Array(userIDs))
+	return err
+}
+
+// GetForMember returns a conversation as seen by userID, or ErrNotFound when
+// they aren't part of it.
+func (s *ConversationStore) GetForMember(ctx context.Context, conversationID, userID int64) (*Conversation, error) {
+	page, err := s.list(ctx, userID, conversationID, math.MaxInt64, math.MaxInt64, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &page[0], nil
+}
+
+// ListForUser returns the conversations of a user, the most recently active
+// first.
+func (s *ConversationStore) ListForUser(ctx context.Context, userID int64, q CursorQuery) (*ConversationPage, error) {
+	beforeMessage, beforeID := int64(math.MaxInt64), int64(math.MaxInt64)
+	if q.Cursor != "" {
+		keys, err := DecodeCursor(q.Cursor, 2)
+		if err != nil {
+			return nil, err
+		}
+		beforeMessage, beforeID = keys[0], keys[1]
+	}
+
+	conversations, err := s.list(ctx, userID, 0, beforeMessage, beforeID, q.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ConversationPage{Conversations: conversations}
+	if len(conversations) > q.Limit {
+		page.Conversations = conversations[:q.Limit]
+
+		last := page.Conversations[q.Limit-1]
+		lastMessageID := int64(0)
+		if last.LastMessage != nil {
+			lastMessageID = last.LastMessage.ID
+		}
+		page.NextCursor = EncodeCursor(lastMessageID, last.ID)
+	}
+
+	return page, nil
+}
+
+// list reads the conversations of userID ordered by last message, then by ID,
+// starting after the given keys. A conversationID other than 0 restricts it to
+// that conversation. Messages from users in a block relationship with userID
+// are neither counted as unread nor shown as the last message.
+func (s *ConversationStore) list(
+	ctx context.Context,
+	userID, conversationID, beforeMessage, beforeID int64,
+	limit int,
+) ([]Conversation, error) {
+	query := `
+  SELECT
+    c.id, c.is_group, c.title, c.created_at,
+    (
+      SELECT COUNT(*) FROM messages m
+      WHERE m.conversation_id = c.id AND m.id > cm.last_read_message_id AND m.sender_id <> $1 AND NOT EXISTS (
+        SELECT 1 FROM user_blocks b
+        WHERE (b.blocker_id = $1 AND b.blocked_id = m.sender_id) OR (b.blocker_id = m.sender_id AND b.blocked_id = $1)
+      )
+    ) AS unread_count,
+    lm.id, lm.sender_id, lm.content, lm.created_at
+  FROM conversation_members cm
+  JOIN conversations c ON c.id = cm.conversation_id
+  LEFT JOIN LATERAL (
+    SELECT m.id, m.sender_id, m.content, m.created_at FROM messages m
+    WHERE m.conversation_id = c.id AND m.id <= c.last_message_id AND NOT EXISTS (
+      SELECT 1 FROM user_blocks b
+      WHERE (b.blocker_id = $1 AND b.blocked_id = m.sender_id) OR (b.blocker_id = m.sender_id AND b.blocked_id = $1)
+    )
+    ORDER BY m.id DESC
+    LIMIT 1
+  ) lm ON true
+  WHERE
+    cm.user_id = $1 AND
+    ($2 = 0 OR c.id = $2) AND
+    (c.last_message_id, c.id) < ($3, $4)
+  ORDER BY c.last_message_id DESC, c.id DESC
+  LIMIT $5
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, conversationID, beforeMessage, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []Conversation{}
+	ids := []int64{}
+	for rows.Next() {
+		var c Conversation
+		var lastID, lastSenderID sql.NullInt64
+		var lastContent, lastCreatedAt sql.NullString
+
+		err := rows.Scan(
+			&c.ID,
+			&c.IsGroup,
+			&c.Title,
+			&c.CreatedAt,
+			&c.UnreadCount,
+			&lastID,
+			&lastSenderID,
+			&lastContent,
+			&lastCreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if lastID.Valid {
+			c.LastMessage = &Message{
+				ID:             lastID.Int64,
+				ConversationID: c.ID,
+				SenderID:       lastSenderID.Int64,
+				Content:        lastContent.String,
+				CreatedAt:      lastCreatedAt.String,
+			}
+		}
+
+		conversations = append(conversations, c)
+		ids = append(ids, c.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	members, err := s.getMembers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range conversations {
+		conversations[i].Members = members[conversations[i].ID]
+	}
+
+	return conversations, nil
+}
+
+func (s *ConversationStore) getMembers(ctx context.Context, conversationIDs []int64) (map[int64][]ConversationMember, error) {
+	query := `
+  SELECT cm.conversation_id, u.id, u.username, cm.last_read_message_id
+  FROM conversation_members cm
+  JOIN users u ON u.id = cm.user_id
+  WHERE cm.conversation_id = ANY($1)
+  ORDER BY cm.joined_at, u.id
+  `
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(conversationIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make(map[int64][]ConversationMember)
+	for rows.Next() {
+		var conversationID int64
+		var m ConversationMember
+		if err := rows.Scan(&conversationID, &m.UserID, &m.Username, &m.LastReadMessageID); err != nil {
+			return nil, err
+		}
+		members[conversationID] = append(members[conversationID], m)
+	}
+
+	return members, rows.Err()
+}
+
+// AddMessage saves a message and marks the conversation read up to it for
+// its sender.
+func (s *ConversationStore) AddMessage(ctx context.Context, message *Message) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+    INSERT INTO messages (conversation_id, sender_id, content)
+    VALUES ($1, $2, $3)
+    RETURNING id, created_at
+    `
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, message.ConversationID, message.SenderID, message.Content).
+			Scan(&message.ID, &message.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		query = `UPDATE conversations SET last_message_id = $2 WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, message.ConversationID, message.ID); err != nil {
+			return err
+		}
+
+		query = `
+    UPDATE conversation_members SET last_read_message_id = $3
+    WHERE conversation_id = $1 AND user_id = $2
+    `
+		_, err = tx.ExecContext(ctx, query, message.ConversationID, message.SenderID, message.ID)
+		return err
+	})
+}
+
+// GetMessages returns the messages of a conversation, newest first, leaving
+// out those of senders in a block relationship with the viewer.
+func (s *ConversationStore) GetMessages(ctx context.Context, conversationID, viewerID int64, q CursorQuery) (*MessagePage, error) {
+	beforeID := int64(math.MaxInt64)
+	if q.Cursor != "" {
+		keys, err := DecodeCursor(q.Cursor, 1)
+		if err != nil {
+			return nil, err
+		}
+		beforeID = keys[0]
+	}
+
+	query := `
+  SELECT m.id, m.conversation_id, m.sender_id, m.content, m.created_at
+  FROM messages m
+  WHERE m.conversation_id = $1 AND m.id < $3 AND NOT EXISTS (
+    SELECT 1 FROM user_blocks b
+    WHERE (b.blocker_id = $2 AND b.blocked_id = m.sender_id) OR (b.blocker_id = m.sender_id AND b.blocked_id = $2)
+  )
+  ORDER BY m.id DESC
+  LIMIT $4
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, conversationID, viewerID, beforeID, q.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.ConversationID, &m.SenderID, &m.Content, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &MessagePage{Messages: messages}
+	if len(messages) > q.Limit {
+		page.Messages = messages[:q.Limit]
+		page.NextCursor = EncodeCursor(page.Messages[q.Limit-1].ID)
+	}
+
+	return page, nil
+}
+
+// MarkRead moves the read marker of a member up to messageID, or to the last
+// message when it is 0, and returns where it now is. It never moves back.
+func (s *ConversationStore) MarkRead(ctx context.Context, conversationID, userID, messageID int64) (int64, error) {
+	query := `
+  UPDATE conversation_members cm
+  SET last_read_message_id = GREATEST(cm.last_read_message_id, LEAST(c.last_message_id, $3))
+  FROM conversations c
+  WHERE c.id = cm.conversation_id AND cm.conversation_id = $1 AND cm.user_id = $2
+  RETURNING cm.last_read_message_id
+  `
+	if messageID == 0 {
+		messageID = math.MaxInt64
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var lastRead int64
+	err := s.db.QueryRowContext(ctx, query, conversationID, userID, messageID).Scan(&lastRead)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return lastRead, nil
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor turns the sort keys of the last item of a page into an opaque
+// cursor for the next page.
+func EncodeCursor(keys ...int64) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = strconv.FormatInt(key, 10)
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(parts, ":")))
+}
+
+// DecodeCursor returns the n sort keys of a cursor made by EncodeCursor.
+func DecodeCursor(cursor string, n int) ([]int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != n {
+		return nil, ErrInvalidCursor
+	}
+
+	keys := make([]int64, n)
+	for i, part := range parts {
+		keys[i], err = strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	return keys, nil
+}
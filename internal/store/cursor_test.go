@@ -0,0 +1,27 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	t.Run("should decode what it encodes", func(t *testing.T) {
+		keys, err := DecodeCursor(EncodeCursor(42, 7), 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(keys, []int64{42, 7}) {
+			t.Errorf("expected [42 7]. got %v", keys)
+		}
+	})
+
+	t.Run("should reject invalid cursors", func(t *testing.T) {
+		for _, cursor := range []string{"not a cursor", EncodeCursor(1), EncodeCursor(1, 2, 3)} {
+			if _, err := DecodeCursor(cursor, 2); err != ErrInvalidCursor {
+				t.Errorf("expected ErrInvalidCursor for %q. got %v", cursor, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/shanisharrma/gopher-social/internal/entities"
+)
+
+// maxMentions bounds how many users a single post or comment can mention, and
+// so notify. Further mentions are still rendered but not stored.
+const maxMentions = 10
+
+// entityTables names the tables linking hashtags and mentions to posts or to
+// comments.
+type entityTables struct {
+	hashtags string
+	mentions string
+	column   string
+}
+
+var (
+	postEntities    = entityTables{hashtags: "post_hashtags", mentions: "post_mentions", column: "post_id"}
+	commentEntities = entityTables{hashtags: "comment_hashtags", mentions: "comment_mentions", column: "comment_id"}
+)
+
+// saveHashtags links id to exactly the given normalised hashtags within tx,
+// creating the ones not seen before. Links that are kept keep their date.
+func saveHashtags(ctx context.Context, tx *sql.Tx, tables entityTables, id int64, hashtags []string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+  DELETE FROM ` + tables.hashtags + `
+  WHERE ` + tables.column + ` = $1 AND hashtag_id NOT IN (SELECT id FROM hashtags WHERE name = ANY($2))
+  `
+	if _, err := tx.ExecContext(ctx, query, id, pq.Array(hashtags)); err != nil {
+		return err
+	}
+
+	if len(hashtags) == 0 {
+		return nil
+	}
+
+	query = `INSERT INTO hashtags (name) SELECT unnest($1::varchar[]) ON CONFLICT (name) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, pq.Array(hashtags)); err != nil {
+		return err
+	}
+
+	query = `
+  INSERT INTO ` + tables.hashtags + ` (` + tables.column + `, hashtag_id)
+  SELECT $1, id FROM hashtags WHERE name = ANY($2)
+  ON CONFLICT DO NOTHING
+  `
+	_, err := tx.ExecContext(ctx, query, id, pq.Array(hashtags))
+	return err
+}
+
+// saveMentions links id to the users mentioned by username within tx and
+// returns the ones mentioned for the first time. Unknown usernames, the author
+// and users in a block relationship with the author are left out.
+func saveMentions(
+	ctx context.Context,
+	tx *sql.Tx,
+	tables entityTables,
+	id, authorID int64,
+	usernames []string,
+) ([]int64, error) {
+	if len(usernames) > maxMentions {
+		usernames = usernames[:maxMentions]
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+  DELETE FROM ` + tables.mentions + `
+  WHERE ` + tables.column + ` = $1 AND user_id NOT IN (SELECT id FROM users WHERE username = ANY($2))
+  `
+	if _, err := tx.ExecContext(ctx, query, id, pq.Array(usernames)); err != nil {
+		return nil, err
+	}
+
+	query = `
+  INSERT INTO ` + tables.mentions + ` (` + tables.column + `, user_id)
+  SELECT $1, u.id FROM users u
+  WHERE u.username = ANY($2) AND u.id <> $3 AND NOT EXISTS (
+    SELECT 1 FROM user_blocks b
+    WHERE (b.blocker_id = $3 AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = $3)
+  )
+  ON CONFLICT DO NOTHING
+  RETURNING user_id
+  `
+	rows, err := tx.QueryContext(ctx, query, id, pq.Array(usernames), authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentioned := []int64{}
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		mentioned = append(mentioned, userID)
+	}
+
+	return mentioned, rows.Err()
+}
+
+// postHashtags merges the tags given by the author with the hashtags of the
+// content, normalised and without duplicates.
+func postHashtags(tags []string, parsed []entities.Entity) []string {
+	all := make([]entities.Entity, 0, len(tags)+len(parsed))
+	for _, tag := range tags {
+		if tag = entities.NormalizeHashtag(tag); tag != "" {
+			all = append(all, entities.Entity{Type: entities.TypeHashtag, Value: tag})
+		}
+	}
+
+	return entities.Values(append(all, parsed...), entities.TypeHashtag)
+}
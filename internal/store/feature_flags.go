@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/audit"
+)
+
+// FeatureFlag turns a feature on for the share of users given by Rollout, a
+// percentage. A flag enabled with a rollout of 100 is on for everyone.
+type FeatureFlag struct {
+	ID          int64     `json:"id"`
+	Key         string    `json:"key"`
+	Enabled     bool      `json:"enabled"`
+	Rollout     int       `json:"rollout"`
+	Description string    `json:"description"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type FeatureFlagStore struct {
+	db    *sql.DB
+	audit audit.Recorder
+}
+
+func (s *FeatureFlagStore) GetAll(ctx context.Context) ([]FeatureFlag, error) {
+	query := `
+  SELECT id, key, enabled, rollout, description, updated_at
+  FROM feature_flags
+  ORDER BY key
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := []FeatureFlag{}
+	for rows.Next() {
+		var flag FeatureFlag
+		if err := rows.Scan(&flag.ID, &flag.Key, &flag.Enabled, &flag.Rollout, &flag.Description, &flag.UpdatedAt); err != nil {
+			return nil, err
+		}
+		flags = append(flags, flag)
+	}
+
+	return flags, rows.Err()
+}
+
+// Set creates or changes a flag. The change is recorded in the audit log.
+func (s *FeatureFlagStore) Set(ctx context.Context, flag *FeatureFlag) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var before *FeatureFlag
+		query := `
+    SELECT id, key, enabled, rollout, description, updated_at
+    FROM feature_flags
+    WHERE key = $1
+    FOR UPDATE
+    `
+		var current FeatureFlag
+		err := tx.QueryRowContext(ctx, query, flag.Key).
+			Scan(&current.ID, &current.Key, &current.Enabled, &current.Rollout, &current.Description, &current.UpdatedAt)
+		switch {
+		case err == nil:
+			before = &current
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+
+		query = `
+    INSERT INTO feature_flags (key, enabled, rollout, description)
+    VALUES ($1, $2, $3, $4)
+    ON CONFLICT (key) DO UPDATE SET
+      enabled = EXCLUDED.enabled,
+      rollout = EXCLUDED.rollout,
+      description = EXCLUDED.description,
+      updated_at = NOW()
+    RETURNING id, updated_at
+    `
+		err = tx.QueryRowContext(ctx, query, flag.Key, flag.Enabled, flag.Rollout, flag.Description).
+			Scan(&flag.ID, &flag.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, s.audit, "feature_flag.update", "feature_flag", flag.ID, before, flag)
+	})
+}
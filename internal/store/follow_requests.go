@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// FollowRequest is a pending request from RequesterID to follow the private
+// account UserID. It becomes a row in followers once approved.
+type FollowRequest struct {
+	UserID      int64  `json:"user_id"`
+	RequesterID int64  `json:"requester_id"`
+	CreatedAt   string `json:"created_at"`
+	Requester   User   `json:"requester"`
+}
+
+type FollowRequestStore struct {
+	db *sql.DB
+}
+
+func (s *FollowRequestStore) Create(ctx context.Context, requesterID, userID int64) error {
+	query := `INSERT INTO follow_requests (user_id, requester_id) VALUES ($1, $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, requesterID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *FollowRequestStore) GetIncoming(ctx context.Context, userID int64) ([]FollowRequest, error) {
+	query := `
+  SELECT fr.user_id, fr.requester_id, fr.created_at, u.id, u.username
+  FROM follow_requests fr
+  JOIN users u ON u.id = fr.requester_id
+  WHERE fr.user_id = $1
+  ORDER BY fr.created_at DESC
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []FollowRequest{}
+	for rows.Next() {
+		var fr FollowRequest
+		err := rows.Scan(
+			&fr.UserID,
+			&fr.RequesterID,
+			&fr.CreatedAt,
+			&fr.Requester.ID,
+			&fr.Requester.Username,
+		)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, fr)
+	}
+
+	return requests, rows.Err()
+}
+
+// Approve turns the pending request into a follower row in a single
+// transaction.
+func (s *FollowRequestStore) Approve(ctx context.Context, requesterID, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.delete(ctx, tx, requesterID, userID); err != nil {
+			return err
+		}
+
+		query := `
+    INSERT INTO followers (user_id, follower_id) VALUES ($1, $2)
+    ON CONFLICT DO NOTHING
+    `
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, query, userID, requesterID)
+		return err
+	})
+}
+
+// Delete removes a pending request. It is used both when the target rejects
+// it and when the requester cancels it.
+func (s *FollowRequestStore) Delete(ctx context.Context, requesterID, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.delete(ctx, tx, requesterID, userID)
+	})
+}
+
+func (s *FollowRequestStore) delete(ctx context.Context, tx *sql.Tx, requesterID, userID int64) error {
+	query := `DELETE FROM follow_requests WHERE user_id = $1 AND requester_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, userID, requesterID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -28,6 +28,7 @@ func (s *FollowerStore) Follow(ctx context.Context, followerID, userID int64) er
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
 			return ErrConflict
 		}
+		return err
 	}
 	return nil
 }
@@ -43,3 +44,53 @@ func (s *FollowerStore) Unfollow(ctx context.Context, followerID, userID int64)
 	_, err := s.db.ExecContext(ctx, query, userID, followerID)
 	return err
 }
+
+// GetFollowerIDs returns the followers of a user who would see their posts
+// in the feed, i.e. leaving out those who muted them.
+func (s *FollowerStore) GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error) {
+	query := `
+  SELECT f.follower_id FROM followers f
+  WHERE f.user_id = $1 AND NOT EXISTS (
+    SELECT 1 FROM user_mutes m WHERE m.muter_id = f.follower_id AND m.muted_id = f.user_id
+  )
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (s *FollowerStore) IsFollowing(ctx context.Context, followerID, userID int64) (bool, error) {
+	query := `
+  SELECT EXISTS (
+    SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2
+  )
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var following bool
+	err := s.db.QueryRowContext(ctx, query, userID, followerID).Scan(&following)
+	if err != nil {
+		return false, err
+	}
+
+	return following, nil
+}
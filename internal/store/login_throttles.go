@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/audit"
+)
+
+// Scopes failed logins are counted in.
+const (
+	LoginScopeAccount = "account"
+	LoginScopeIP      = "ip"
+)
+
+// loginFailureWindow is how long failures are remembered. A key with no
+// failure for that long starts counting again from zero.
+const loginFailureWindow = 24 * time.Hour
+
+// LoginThrottle is the count of recent failed logins of a key, which is an
+// email in the account scope and an address in the IP scope.
+type LoginThrottle struct {
+	Failures int
+	// LockedUntil is when logins are allowed again, nil when they are.
+	LockedUntil *time.Time
+}
+
+type LoginThrottleStore struct {
+	db    *sql.DB
+	audit audit.Recorder
+}
+
+// LoginKey normalises the key of a scope, so that variants of an email count
+// as one.
+func LoginKey(scope, key string) string {
+	if scope == LoginScopeAccount {
+		return strings.ToLower(strings.TrimSpace(key))
+	}
+	return key
+}
+
+// Get returns the throttle of a key, with no failures when there is none.
+func (s *LoginThrottleStore) Get(ctx context.Context, scope, key string) (*LoginThrottle, error) {
+	query := `
+  SELECT failures, CASE WHEN locked_until > NOW() THEN locked_until END
+  FROM login_throttles
+  WHERE scope = $1 AND key = $2 AND last_failure_at > NOW() - $3::float8 * interval '1 second'
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var throttle LoginThrottle
+	err := s.db.QueryRowContext(ctx, query, scope, LoginKey(scope, key), loginFailureWindow.Seconds()).
+		Scan(&throttle.Failures, &throttle.LockedUntil)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return &LoginThrottle{}, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &throttle, nil
+}
+
+// RecordFailure counts a failed login of a key and locks the key for as long
+// as lockFor gives for the new count, if at all.
+func (s *LoginThrottleStore) RecordFailure(
+	ctx context.Context,
+	scope, key string,
+	lockFor func(failures int) time.Duration,
+) (*LoginThrottle, error) {
+	var throttle LoginThrottle
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+    INSERT INTO login_throttles (scope, key, failures)
+    VALUES ($1, $2, 1)
+    ON CONFLICT (scope, key) DO UPDATE SET
+      failures = CASE
+        WHEN login_throttles.last_failure_at > NOW() - $3::float8 * interval '1 second'
+        THEN login_throttles.failures + 1
+        ELSE 1
+      END,
+      last_failure_at = NOW(),
+      locked_until = NULL
+    RETURNING failures
+    `
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		key = LoginKey(scope, key)
+		if err := tx.QueryRowContext(ctx, query, scope, key, loginFailureWindow.Seconds()).Scan(&throttle.Failures); err != nil {
+			return err
+		}
+
+		delay := lockFor(throttle.Failures)
+		if delay <= 0 {
+			return nil
+		}
+
+		query = `
+    UPDATE login_throttles SET locked_until = NOW() + $3::float8 * interval '1 second'
+    WHERE scope = $1 AND key = $2
+    RETURNING locked_until
+    `
+		return tx.QueryRowContext(ctx, query, scope, key, delay.Seconds()).Scan(&throttle.LockedUntil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &throttle, nil
+}
+
+// Reset forgets the failed logins of a key.
+func (s *LoginThrottleStore) Reset(ctx context.Context, scope, key string) error {
+	query := `DELETE FROM login_throttles WHERE scope = $1 AND key = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, scope, LoginKey(scope, key))
+	return err
+}
+
+// Unlock forgets the failed logins of the account of a user, lifting its
+// lockout. It is meant for admins and recorded in the audit log.
+func (s *LoginThrottleStore) Unlock(ctx context.Context, user *User) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+    DELETE FROM login_throttles WHERE scope = $1 AND key = $2
+    RETURNING failures, CASE WHEN locked_until > NOW() THEN locked_until END
+    `
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var before LoginThrottle
+		err := tx.QueryRowContext(ctx, query, LoginScopeAccount, LoginKey(LoginScopeAccount, user.Email)).
+			Scan(&before.Failures, &before.LockedUntil)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				// nothing to unlock
+				return nil
+			default:
+				return err
+			}
+		}
+
+		return recordAudit(ctx, tx, s.audit, "user.unlock", "user", user.ID, before, nil)
+	})
+}
@@ -3,22 +3,84 @@ package store
 import (
 	"context"
 	"database/sql"
+	"sync"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Posts:          &MockPostStore{},
+		Users:          &MockUserStore{},
+		Blocks:         &MockBlockStore{},
+		Roles:          &MockRoleStore{},
+		Reports:        &MockReportStore{},
+		Suspensions:    &MockSuspensionStore{},
+		Communities:    &MockCommunityStore{},
+		Polls:          &MockPollStore{},
+		Followers:      &MockFollowerStore{},
+		LoginThrottles: &MockLoginThrottleStore{throttles: map[string]*LoginThrottle{}},
+		FeatureFlags:   &MockFeatureFlagStore{flags: map[string]FeatureFlag{}},
 	}
 }
 
+type MockPostStore struct{}
+
+func (m MockPostStore) Create(ctx context.Context, post *Post) error {
+	return nil
+}
+func (m MockPostStore) GetById(ctx context.Context, postID int64) (*Post, error) {
+	return &Post{ID: postID, UserID: 1}, nil
+}
+func (m MockPostStore) Delete(ctx context.Context, postID int64) error {
+	return nil
+}
+func (m MockPostStore) Update(ctx context.Context, post *Post) error {
+	return nil
+}
+func (m MockPostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+func (m MockPostStore) GetByTag(ctx context.Context, tag string, viewerID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+func (m MockPostStore) GetByIds(ctx context.Context, ids []int64, viewerID int64) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
+func (m MockPostStore) GetByUser(ctx context.Context, userID, viewerID int64, q UserPostsQuery) (*PostPage, error) {
+	return &PostPage{Posts: []PostWithMetadata{}}, nil
+}
+
+func (m MockPostStore) Pin(ctx context.Context, postID int64) error {
+	return nil
+}
+
+func (m MockPostStore) Unpin(ctx context.Context, postID int64) error {
+	return nil
+}
+
+func (m MockPostStore) GetByCommunity(ctx context.Context, communityID, viewerID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
 type MockUserStore struct{}
 
 func (m MockUserStore) GetById(ctx context.Context, userID int64) (*User, error) {
-	return nil, nil
+	return &User{ID: userID}, nil
 }
+
+// MockUserPassword is the password of the users MockUserStore returns.
+const MockUserPassword = "password123"
+
 func (m MockUserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
-	return nil, nil
+	hash, err := bcrypt.GenerateFromPassword([]byte(MockUserPassword), bcrypt.MinCost)
+	if err != nil {
+		return nil, err
+	}
+
+	return &User{ID: 1, Email: email, Password: password{hash: hash}}, nil
 }
 func (m MockUserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 	return nil
@@ -32,3 +94,209 @@ func (m MockUserStore) Activate(ctx context.Context, token string) error {
 func (m MockUserStore) Delete(ctx context.Context, userID int64) error {
 	return nil
 }
+func (m MockUserStore) SetPrivacy(ctx context.Context, userID int64, isPrivate bool) error {
+	return nil
+}
+func (m MockUserStore) SetRole(ctx context.Context, userID int64, roleName string) error {
+	return nil
+}
+
+type MockBlockStore struct{}
+
+func (m MockBlockStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	return nil
+}
+func (m MockBlockStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	return nil
+}
+func (m MockBlockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	return false, nil
+}
+func (m MockBlockStore) GetBlocked(ctx context.Context, userID int64) ([]User, error) {
+	return []User{}, nil
+}
+
+type MockRoleStore struct{}
+
+func (m MockRoleStore) GetByName(ctx context.Context, roleName string) (*Role, error) {
+	levels := map[string]int{"user": 1, "moderator": 2, "admin": 3}
+
+	return &Role{Name: roleName, Level: levels[roleName]}, nil
+}
+
+type MockReportStore struct{}
+
+func (m MockReportStore) Create(ctx context.Context, report *Report) error {
+	return nil
+}
+func (m MockReportStore) GetById(ctx context.Context, reportID int64) (*Report, error) {
+	return &Report{ID: reportID, ReporterID: 1, TargetType: ReportTargetUser, TargetID: 1, ReportedUserID: 1, Status: ReportStatusOpen}, nil
+}
+func (m MockReportStore) List(ctx context.Context, q ReportQuery) ([]Report, error) {
+	return []Report{}, nil
+}
+func (m MockReportStore) GetActions(ctx context.Context, reportID int64) ([]ModerationAction, error) {
+	return []ModerationAction{}, nil
+}
+func (m MockReportStore) Resolve(ctx context.Context, report *Report, action *ModerationAction) error {
+	return nil
+}
+
+type MockSuspensionStore struct{}
+
+func (m MockSuspensionStore) Create(ctx context.Context, suspension *Suspension) error {
+	return nil
+}
+func (m MockSuspensionStore) GetByUser(ctx context.Context, userID int64) ([]Suspension, error) {
+	return []Suspension{}, nil
+}
+
+// MockCommunityStore holds a single invite only community nobody was invited
+// to.
+type MockCommunityStore struct{}
+
+func (m MockCommunityStore) Create(ctx context.Context, community *Community) error {
+	return nil
+}
+func (m MockCommunityStore) GetById(ctx context.Context, id, viewerID int64) (*Community, error) {
+	return &Community{ID: id, Visibility: CommunityInviteOnly, OwnerID: 2}, nil
+}
+func (m MockCommunityStore) GetRole(ctx context.Context, communityID, userID int64) (string, error) {
+	return "", nil
+}
+func (m MockCommunityStore) Join(ctx context.Context, community *Community, userID int64) error {
+	return ErrNotInvited
+}
+func (m MockCommunityStore) Leave(ctx context.Context, communityID, userID int64) error {
+	return nil
+}
+func (m MockCommunityStore) Invite(ctx context.Context, communityID, userID, invitedBy int64) error {
+	return nil
+}
+func (m MockCommunityStore) GetInvitations(ctx context.Context, userID int64) ([]Community, error) {
+	return []Community{}, nil
+}
+func (m MockCommunityStore) GetForUser(ctx context.Context, userID, viewerID int64) ([]Community, error) {
+	return []Community{}, nil
+}
+func (m MockCommunityStore) GetMembers(ctx context.Context, communityID int64, q CursorQuery) (*CommunityMemberPage, error) {
+	return &CommunityMemberPage{Members: []CommunityMember{}}, nil
+}
+func (m MockCommunityStore) SetRole(ctx context.Context, communityID, userID int64, role string) error {
+	return nil
+}
+func (m MockCommunityStore) RemoveMember(ctx context.Context, communityID, userID int64) error {
+	return nil
+}
+
+type MockPollStore struct{}
+
+func (m MockPollStore) GetForPosts(ctx context.Context, postIDs []int64, viewerID int64) (map[int64]*Poll, error) {
+	return map[int64]*Poll{}, nil
+}
+func (m MockPollStore) Vote(ctx context.Context, pollID, userID int64, optionIDs []int64) error {
+	return nil
+}
+
+type MockFollowerStore struct{}
+
+func (m MockFollowerStore) Follow(ctx context.Context, followerID, userID int64) error {
+	return nil
+}
+func (m MockFollowerStore) Unfollow(ctx context.Context, followerID, userID int64) error {
+	return nil
+}
+func (m MockFollowerStore) IsFollowing(ctx context.Context, followerID, userID int64) (bool, error) {
+	return false, nil
+}
+func (m MockFollowerStore) GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error) {
+	return []int64{}, nil
+}
+
+// MockLoginThrottleStore counts failures in memory.
+type MockLoginThrottleStore struct {
+	mu        sync.Mutex
+	throttles map[string]*LoginThrottle
+}
+
+func (m *MockLoginThrottleStore) Get(ctx context.Context, scope, key string) (*LoginThrottle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	throttle, ok := m.throttles[scope+":"+LoginKey(scope, key)]
+	if !ok {
+		return &LoginThrottle{}, nil
+	}
+
+	current := *throttle
+	if current.LockedUntil != nil && !current.LockedUntil.After(time.Now()) {
+		current.LockedUntil = nil
+	}
+	return &current, nil
+}
+
+func (m *MockLoginThrottleStore) RecordFailure(ctx context.Context, scope, key string, lockFor func(int) time.Duration) (*LoginThrottle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k := scope + ":" + LoginKey(scope, key)
+	throttle, ok := m.throttles[k]
+	if !ok {
+		throttle = &LoginThrottle{}
+		m.throttles[k] = throttle
+	}
+
+	throttle.Failures++
+	throttle.LockedUntil = nil
+	if delay := lockFor(throttle.Failures); delay > 0 {
+		until := time.Now().Add(delay)
+		throttle.LockedUntil = &until
+	}
+
+	current := *throttle
+	return &current, nil
+}
+
+func (m *MockLoginThrottleStore) Reset(ctx context.Context, scope, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.throttles, scope+":"+LoginKey(scope, key))
+	return nil
+}
+
+func (m *MockLoginThrottleStore) Unlock(ctx context.Context, user *User) error {
+	return m.Reset(ctx, LoginScopeAccount, user.Email)
+}
+
+// MockFeatureFlagStore keeps flags in memory.
+type MockFeatureFlagStore struct {
+	mu    sync.Mutex
+	flags map[string]FeatureFlag
+}
+
+func (m *MockFeatureFlagStore) GetAll(ctx context.Context) ([]FeatureFlag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	flags := []FeatureFlag{}
+	for _, flag := range m.flags {
+		flags = append(flags, flag)
+	}
+	return flags, nil
+}
+
+func (m *MockFeatureFlagStore) Set(ctx context.Context, flag *FeatureFlag) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if current, ok := m.flags[flag.Key]; ok {
+		flag.ID = current.ID
+	} else {
+		flag.ID = int64(len(m.flags) + 1)
+	}
+	flag.UpdatedAt = time.Now()
+	m.flags[flag.Key] = *flag
+
+	return nil
+}
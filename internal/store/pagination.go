@@ -1,10 +1,13 @@
 package store
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/entities"
 )
 
 type PaginatedFeedQuery struct {
@@ -47,7 +50,7 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 
 	tags := qs.Get("tags")
 	if tags != "" {
-		fq.Tags = strings.Split(tags, ",")
+		fq.Tags = parseTags(tags)
 	}
 
 	search := qs.Get("search")
@@ -68,6 +71,19 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 	return fq, nil
 }
 
+// parseTags splits a comma separated list of tags, normalised the way the
+// tags of posts are saved.
+func parseTags(str string) []string {
+	var tags []string
+	for _, tag := range strings.Split(str, ",") {
+		if tag = entities.NormalizeHashtag(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
 func parseTime(str string) *time.Time {
 	if str == "" {
 		return nil
@@ -80,3 +96,63 @@ func parseTime(str string) *time.Time {
 
 	return &t
 }
+
+// CursorQuery pages through a list, newest first. Cursor is the NextCursor of
+// the previous page, and is empty for the first one.
+type CursorQuery struct {
+	Limit  int    `json:"limit"  validate:"gte=1,lte=50"`
+	Cursor string `json:"cursor"`
+}
+
+func (q CursorQuery) Parse(r *http.Request) (CursorQuery, error) {
+	qs := r.URL.Query()
+
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, errors.New("limit must be a number")
+		}
+
+		q.Limit = l
+	}
+
+	q.Cursor = qs.Get("cursor")
+
+	return q, nil
+}
+
+// UserPostsQuery pages through the posts of a user, newest first, keeping
+// those with all of Tags and created between Since and Until.
+type UserPostsQuery struct {
+	CursorQuery
+	Tags  []string   `json:"tags"  validate:"max=5"`
+	Since *time.Time `json:"since"`
+	Until *time.Time `json:"until"`
+}
+
+func (q UserPostsQuery) Parse(r *http.Request) (UserPostsQuery, error) {
+	var err error
+	if q.CursorQuery, err = q.CursorQuery.Parse(r); err != nil {
+		return q, err
+	}
+
+	qs := r.URL.Query()
+
+	if tags := qs.Get("tags"); tags != "" {
+		q.Tags = parseTags(tags)
+	}
+
+	if since := qs.Get("since"); since != "" {
+		if q.Since = parseTime(since); q.Since == nil {
+			return q, errors.New("since must be an RFC 3339 time")
+		}
+	}
+
+	if until := qs.Get("until"); until != "" {
+		if q.Until = parseTime(until); q.Until == nil {
+			return q, errors.New("until must be an RFC 3339 time")
+		}
+	}
+
+	return q, nil
+}
@@ -0,0 +1,22 @@
+package store
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPaginatedFeedQuery(t *testing.T) {
+	t.Run("should normalise tags like those of posts", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/?tags=%23GoLang,,Caf%C3%89", nil)
+
+		fq, err := PaginatedFeedQuery{}.Parse(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(fq.Tags, []string{"golang", "café"}) {
+			t.Errorf("expected [golang café]. got %v", fq.Tags)
+		}
+	})
+}
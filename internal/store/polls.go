@@ -0,0 +1,234 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	MinPollOptions = 2
+	MaxPollOptions = 6
+)
+
+var (
+	ErrPollClosed    = errors.New("poll is closed")
+	ErrInvalidChoice = errors.New("invalid poll choice")
+)
+
+// Poll is attached to a post. Until the viewer it was fetched for votes, or
+// the poll closes, the vote counts are left out.
+type Poll struct {
+	ID             int64        `json:"id"`
+	MultipleChoice bool         `json:"multiple_choice"`
+	ClosesAt       time.Time    `json:"closes_at"`
+	Closed         bool         `json:"closed"`
+	Options        []PollOption `json:"options"`
+	// Voted reports whether the viewer voted, and ViewerChoices what for.
+	Voted         bool    `json:"voted"`
+	ViewerChoices []int64 `json:"viewer_choices,omitempty"`
+	// Voters is the number of users who voted, nil while results are hidden.
+	Voters *int `json:"voters,omitempty"`
+}
+
+type PollOption struct {
+	ID    int64  `json:"id"`
+	Text  string `json:"text"`
+	Votes *int   `json:"votes,omitempty"`
+}
+
+type PollStore struct {
+	db *sql.DB
+}
+
+// createPoll saves the poll of a post within tx, filling in the IDs.
+func createPoll(ctx context.Context, tx *sql.Tx, postID int64, poll *Poll) error {
+	query := `
+  INSERT INTO polls (post_id, multiple_choice, closes_at)
+  VALUES ($1, $2, $3)
+  RETURNING id
+  `
+	if err := tx.QueryRowContext(ctx, query, postID, poll.MultipleChoice, poll.ClosesAt).Scan(&poll.ID); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO poll_options (poll_id, position, text) VALUES ($1, $2, $3) RETURNING id`
+	for i := range poll.Options {
+		if err := tx.QueryRowContext(ctx, query, poll.ID, i, poll.Options[i].Text).Scan(&poll.Options[i].ID); err != nil {
+			return err
+		}
+	}
+
+	poll.Closed = !poll.ClosesAt.After(time.Now())
+	return nil
+}
+
+// GetForPosts returns the polls of the given posts, by post ID, as seen by
+// viewerID.
+func (s *PollStore) GetForPosts(ctx context.Context, postIDs []int64, viewerID int64) (map[int64]*Poll, error) {
+	return getPolls(ctx, s.db, postIDs, viewerID)
+}
+
+// Vote records the choices of a user. Each user votes once, for a single
+// option unless the poll is multiple choice; voting again fails with
+// ErrConflict.
+func (s *PollStore) Vote(ctx context.Context, pollID, userID int64, optionIDs []int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT multiple_choice, closes_at <= NOW() FROM polls WHERE id = $1 FOR SHARE`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var multipleChoice, closed bool
+		err := tx.QueryRowContext(ctx, query, pollID).Scan(&multipleChoice, &closed)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if closed {
+			return ErrPollClosed
+		}
+
+		if len(optionIDs) == 0 || (len(optionIDs) > 1 && !multipleChoice) {
+			return ErrInvalidChoice
+		}
+
+		query = `SELECT COUNT(*) FROM poll_options WHERE poll_id = $1 AND id = ANY($2)`
+
+		var valid int
+		if err := tx.QueryRowContext(ctx, query, pollID, pq.Array(optionIDs)).Scan(&valid); err != nil {
+			return err
+		}
+
+		if valid != len(optionIDs) {
+			return ErrInvalidChoice
+		}
+
+		query = `INSERT INTO poll_voters (poll_id, user_id) VALUES ($1, $2)`
+		if _, err := tx.ExecContext(ctx, query, pollID, userID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		query = `INSERT INTO poll_votes (poll_id, user_id, option_id) SELECT $1, $2, unnest($3::bigint[])`
+		_, err = tx.ExecContext(ctx, query, pollID, userID, pq.Array(optionIDs))
+		return err
+	})
+}
+
+// attachPolls sets the polls of a feed, as seen by viewerID.
+func attachPolls(ctx context.Context, db *sql.DB, feed []PostWithMetadata, viewerID int64) error {
+	if len(feed) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(feed))
+	for i, p := range feed {
+		ids[i] = p.ID
+	}
+
+	polls, err := getPolls(ctx, db, ids, viewerID)
+	if err != nil {
+		return err
+	}
+
+	for i := range feed {
+		feed[i].Poll = polls[feed[i].ID]
+	}
+
+	return nil
+}
+
+func getPolls(ctx context.Context, db *sql.DB, postIDs []int64, viewerID int64) (map[int64]*Poll, error) {
+	query := `
+  SELECT
+    p.id, p.post_id, p.multiple_choice, p.closes_at, p.closes_at <= NOW(),
+    (SELECT COUNT(*) FROM poll_voters v WHERE v.poll_id = p.id),
+    EXISTS (SELECT 1 FROM poll_voters v WHERE v.poll_id = p.id AND v.user_id = $2)
+  FROM polls p
+  WHERE p.post_id = ANY($1)
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(postIDs), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byPost := map[int64]*Poll{}
+	byID := map[int64]*Poll{}
+	pollIDs := []int64{}
+	for rows.Next() {
+		var poll Poll
+		var postID int64
+		var voters int
+		err := rows.Scan(&poll.ID, &postID, &poll.MultipleChoice, &poll.ClosesAt, &poll.Closed, &voters, &poll.Voted)
+		if err != nil {
+			return nil, err
+		}
+
+		poll.Options = []PollOption{}
+		if poll.Voted || poll.Closed {
+			poll.Voters = &voters
+		}
+
+		byPost[postID] = &poll
+		byID[poll.ID] = &poll
+		pollIDs = append(pollIDs, poll.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(pollIDs) == 0 {
+		return byPost, nil
+	}
+
+	query = `
+  SELECT o.id, o.poll_id, o.text, COUNT(v.user_id), COALESCE(BOOL_OR(v.user_id = $2), false)
+  FROM poll_options o
+  LEFT JOIN poll_votes v ON v.option_id = o.id
+  WHERE o.poll_id = ANY($1)
+  GROUP BY o.id
+  ORDER BY o.poll_id, o.position
+  `
+	rows, err = db.QueryContext(ctx, query, pq.Array(pollIDs), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var option PollOption
+		var pollID int64
+		var votes int
+		var chosen bool
+		if err := rows.Scan(&option.ID, &pollID, &option.Text, &votes, &chosen); err != nil {
+			return nil, err
+		}
+
+		poll := byID[pollID]
+		if poll.Voters != nil {
+			option.Votes = &votes
+		}
+		if chosen {
+			poll.ViewerChoices = append(poll.ViewerChoices, option.ID)
+		}
+
+		poll.Options = append(poll.Options, option)
+	}
+
+	return byPost, rows.Err()
+}
@@ -5,21 +5,45 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
 
 	"github.com/lib/pq"
+	"github.com/shanisharrma/gopher-social/internal/audit"
+	"github.com/shanisharrma/gopher-social/internal/entities"
+	"github.com/shanisharrma/gopher-social/internal/markdown"
 )
 
 type Post struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Title     string    `json:"title"`
-	UserID    int64     `json:"user_id"`
-	Tags      []string  `json:"tags"`
-	CreatedAt string    `json:"created_at"`
-	UpdatedAt string    `json:"updated_at"`
-	Version   int       `json:"version"`
-	Comments  []Comment `json:"comments"`
-	User      User      `json:"user"`
+	ID      int64  `json:"id"`
+	Content string `json:"content"`
+	// ContentHTML is Content rendered from Markdown and sanitised, safe for
+	// clients to embed as is.
+	ContentHTML string `json:"content_html"`
+	Title       string `json:"title"`
+	UserID      int64  `json:"user_id"`
+	// Tags are the AuthorTags together with the hashtags of Content.
+	Tags []string `json:"tags"`
+	// AuthorTags are the tags set by the author, kept apart so that hashtags
+	// removed from Content also leave Tags.
+	AuthorTags []string `json:"-"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+	Version    int      `json:"version"`
+	IsHidden   bool     `json:"is_hidden"`
+	// CommunityID is the community the post was shared in, if any. It decides
+	// who can see the post and who moderates it.
+	CommunityID *int64 `json:"community_id,omitempty"`
+	Poll        *Poll  `json:"poll,omitempty"`
+	// Pinned is only set when listing the posts of a user.
+	Pinned   bool      `json:"pinned,omitempty"`
+	Comments []Comment `json:"comments"`
+	User     User      `json:"user"`
+	// Entities are the mentions and hashtags of Content, for clients to
+	// render links.
+	Entities []entities.Entity `json:"entities"`
+	// MentionedIDs are the users mentioned for the first time by the last
+	// Create or Update, who are to be notified.
+	MentionedIDs []int64 `json:"-"`
 }
 
 type PostWithMetadata struct {
@@ -27,8 +51,19 @@ type PostWithMetadata struct {
 	CommentsCount int `json:"comments_count"`
 }
 
+type PostPage struct {
+	Posts      []PostWithMetadata `json:"posts"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// MaxPinnedPosts is how many posts users can pin to their profile.
+const MaxPinnedPosts = 3
+
+var ErrPinLimit = errors.New("too many pinned posts")
+
 type PostStore struct {
-	db *sql.DB
+	db    *sql.DB
+	audit audit.Recorder
 }
 
 func (s *PostStore) GetUserFeed(
@@ -36,21 +71,32 @@ func (s *PostStore) GetUserFeed(
 	userID int64,
 	fq PaginatedFeedQuery,
 ) ([]PostWithMetadata, error) {
+	// Only the viewer's own posts and posts of accounts they follow are
+	// included. Pending follow requests never produce a followers row, so a
+	// requester sees nothing from a private account until it is approved.
+	// Authors in a block relationship with the viewer, or muted by them, are
+	// left out, and so is content hidden by moderators.
 	query := `
   SELECT
-    p.id,p.user_id,p.title,p.content,p.created_at,p.version,p.tags,
+    p.id,p.user_id,p.title,p.content,p.content_html,p.created_at,p.version,p.tags,p.community_id,
     u.username,
     COUNT(c.id) AS comments_count
   FROM posts p
-  LEFT JOIN comments c ON c.post_id = p.id
-  LEFT JOIN users u ON c.user_id = u.id
-  JOIN followers f ON f.follower_id = p.user_id OR p.user_id = $1
+  LEFT JOIN comments c ON c.post_id = p.id AND NOT c.is_hidden
+  LEFT JOIN users u ON p.user_id = u.id
   WHERE 
-    f.user_id = $1 OR p.user_id = $1 AND
+    NOT p.is_hidden AND
+    (p.user_id = $1 OR p.user_id IN (SELECT user_id FROM followers WHERE follower_id = $1)) AND
+    NOT EXISTS (
+      SELECT 1 FROM user_blocks b
+      WHERE (b.blocker_id = $1 AND b.blocked_id = p.user_id) OR (b.blocker_id = p.user_id AND b.blocked_id = $1)
+    ) AND
+    NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.muter_id = $1 AND m.muted_id = p.user_id) AND
+    ` + inVisibleCommunity("$1") + ` AND
     (p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
     (p.tags @> $5 OR array_length($5, 1) IS NULL or array_length($5, 1) = 0) AND
-    (p.created_at >= $6) AND
-    (p.created_at <= $7)
+    ($6::timestamptz IS NULL OR p.created_at >= $6) AND
+    ($7::timestamptz IS NULL OR p.created_at <= $7)
   GROUP BY p.id, u.username
   ORDER BY p.created_at ` + fq.Sort + `
   LIMIT $2 OFFSET $3;
@@ -77,7 +123,307 @@ func (s *PostStore) GetUserFeed(
 
 	defer rows.Close()
 
-	var feed []PostWithMetadata
+	feed, err := scanFeed(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return feed, attachPolls(ctx, s.db, feed, userID)
+}
+
+// GetByUser returns the posts of a user that viewerID is allowed to see, with
+// the same exclusions as the feed. Their pinned posts come first, on the first
+// page only.
+func (s *PostStore) GetByUser(ctx context.Context, userID, viewerID int64, q UserPostsQuery) (*PostPage, error) {
+	beforeID := int64(math.MaxInt64)
+	if q.Cursor != "" {
+		keys, err := DecodeCursor(q.Cursor, 1)
+		if err != nil {
+			return nil, err
+		}
+		beforeID = keys[0]
+	}
+
+	query := `
+  SELECT
+    p.id,p.user_id,p.title,p.content,p.content_html,p.created_at,p.version,p.tags,p.community_id,
+    u.username,
+    COUNT(c.id) AS comments_count
+  FROM posts p
+  JOIN users u ON u.id = p.user_id
+  LEFT JOIN comments c ON c.post_id = p.id AND NOT c.is_hidden
+  WHERE
+    p.user_id = $1 AND ` + visibleToViewer + ` AND
+    (p.tags @> $3 OR array_length($3, 1) IS NULL OR array_length($3, 1) = 0) AND
+    ($4::timestamptz IS NULL OR p.created_at >= $4) AND
+    ($5::timestamptz IS NULL OR p.created_at <= $5) AND
+    %s
+  GROUP BY p.id, u.username
+  ORDER BY %s
+  LIMIT $6
+  `
+	pinnedQuery := fmt.Sprintf(query, "p.pinned_at IS NOT NULL", "p.pinned_at DESC")
+	postsQuery := fmt.Sprintf(query, "p.pinned_at IS NULL AND p.id < $7", "p.id DESC")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := []any{userID, viewerID, pq.Array(q.Tags), q.Since, q.Until}
+
+	page := &PostPage{Posts: []PostWithMetadata{}}
+	if q.Cursor == "" {
+		pinned, err := s.queryFeed(ctx, pinnedQuery, append(args, MaxPinnedPosts)...)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range pinned {
+			pinned[i].Pinned = true
+		}
+		page.Posts = pinned
+	}
+
+	posts, err := s.queryFeed(ctx, postsQuery, append(args, q.Limit+1, beforeID)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(posts) > q.Limit {
+		posts = posts[:q.Limit]
+		page.NextCursor = EncodeCursor(posts[q.Limit-1].ID)
+	}
+
+	page.Posts = append(page.Posts, posts...)
+
+	return page, attachPolls(ctx, s.db, page.Posts, viewerID)
+}
+
+func (s *PostStore) queryFeed(ctx context.Context, query string, args ...any) ([]PostWithMetadata, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFeed(rows)
+}
+
+// Pin pins a post to the profile of its author, who can pin up to
+// MaxPinnedPosts of them. Pinning a pinned post does nothing.
+func (s *PostStore) Pin(ctx context.Context, postID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE posts SET pinned_at = NOW() WHERE id = $1 AND pinned_at IS NULL RETURNING user_id`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var userID int64
+		err := tx.QueryRowContext(ctx, query, postID).Scan(&userID)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return nil
+			default:
+				return err
+			}
+		}
+
+		// the author is locked so concurrent pins are counted one at a time
+		query = `SELECT id FROM users WHERE id = $1 FOR UPDATE`
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		query = `SELECT COUNT(*) FROM posts WHERE user_id = $1 AND pinned_at IS NOT NULL`
+
+		var pinned int
+		if err := tx.QueryRowContext(ctx, query, userID).Scan(&pinned); err != nil {
+			return err
+		}
+
+		if pinned > MaxPinnedPosts {
+			return ErrPinLimit
+		}
+
+		return nil
+	})
+}
+
+// Unpin takes a post off the profile of its author.
+func (s *PostStore) Unpin(ctx context.Context, postID int64) error {
+	query := `UPDATE posts SET pinned_at = NULL WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, postID)
+	return err
+}
+
+// GetByTag returns the posts with a hashtag that viewerID is allowed to see,
+// with the same exclusions as the feed. The tag must be normalised.
+func (s *PostStore) GetByTag(
+	ctx context.Context,
+	tag string,
+	viewerID int64,
+	fq PaginatedFeedQuery,
+) ([]PostWithMetadata, error) {
+	query := `
+  SELECT
+    p.id,p.user_id,p.title,p.content,p.content_html,p.created_at,p.version,p.tags,p.community_id,
+    u.username,
+    COUNT(c.id) AS comments_count
+  FROM post_hashtags ph
+  JOIN hashtags h ON h.id = ph.hashtag_id
+  JOIN posts p ON p.id = ph.post_id
+  JOIN users u ON u.id = p.user_id
+  LEFT JOIN comments c ON c.post_id = p.id AND NOT c.is_hidden
+  WHERE
+    h.name = $1 AND ` + visibleToViewer + ` AND
+    ($5::timestamptz IS NULL OR p.created_at >= $5) AND
+    ($6::timestamptz IS NULL OR p.created_at <= $6)
+  GROUP BY p.id, u.username
+  ORDER BY p.created_at ` + fq.Sort + `
+  LIMIT $3 OFFSET $4;
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, tag, viewerID, fq.Limit, fq.Offset, fq.Since, fq.Until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feed, err := scanFeed(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return feed, attachPolls(ctx, s.db, feed, viewerID)
+}
+
+// GetByIds returns the posts with the given IDs that viewerID is allowed to
+// see, in no particular order.
+func (s *PostStore) GetByIds(ctx context.Context, ids []int64, viewerID int64) ([]PostWithMetadata, error) {
+	query := `
+  SELECT
+    p.id,p.user_id,p.title,p.content,p.content_html,p.created_at,p.version,p.tags,p.community_id,
+    u.username,
+    COUNT(c.id) AS comments_count
+  FROM posts p
+  JOIN users u ON u.id = p.user_id
+  LEFT JOIN comments c ON c.post_id = p.id AND NOT c.is_hidden
+  WHERE p.id = ANY($1) AND ` + visibleToViewer + `
+  GROUP BY p.id, u.username
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feed, err := scanFeed(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return feed, attachPolls(ctx, s.db, feed, viewerID)
+}
+
+// GetByCommunity returns the posts of a community, with the same exclusions as
+// the feed. Callers check that viewerID may see the community.
+func (s *PostStore) GetByCommunity(
+	ctx context.Context,
+	communityID int64,
+	viewerID int64,
+	fq PaginatedFeedQuery,
+) ([]PostWithMetadata, error) {
+	query := `
+  SELECT
+    p.id,p.user_id,p.title,p.content,p.content_html,p.created_at,p.version,p.tags,p.community_id,
+    u.username,
+    COUNT(c.id) AS comments_count
+  FROM posts p
+  JOIN users u ON u.id = p.user_id
+  LEFT JOIN comments c ON c.post_id = p.id AND NOT c.is_hidden
+  WHERE
+    p.community_id = $1 AND ` + visibleToViewer + ` AND
+    (p.title ILIKE '%' || $5 || '%' OR p.content ILIKE '%' || $5 || '%') AND
+    (p.tags @> $6 OR array_length($6, 1) IS NULL OR array_length($6, 1) = 0) AND
+    ($7::timestamptz IS NULL OR p.created_at >= $7) AND
+    ($8::timestamptz IS NULL OR p.created_at <= $8)
+  GROUP BY p.id, u.username
+  ORDER BY p.created_at ` + fq.Sort + `
+  LIMIT $3 OFFSET $4;
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		query,
+		communityID,
+		viewerID,
+		fq.Limit,
+		fq.Offset,
+		fq.Search,
+		pq.Array(fq.Tags),
+		fq.Since,
+		fq.Until,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feed, err := scanFeed(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return feed, attachPolls(ctx, s.db, feed, viewerID)
+}
+
+// visibleToViewer is the condition for a post p, written by u, to be shown to
+// the viewer passed as $2 outside of their feed: it isn't hidden, its author
+// is public or followed by the viewer, neither blocked the other nor is muted
+// by the viewer, and its community, if any, is public or has the viewer as a
+// member.
+var visibleToViewer = `
+    NOT p.is_hidden AND
+    (
+      p.user_id = $2 OR NOT u.is_private OR
+      EXISTS (SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $2)
+    ) AND
+    NOT EXISTS (
+      SELECT 1 FROM user_blocks b
+      WHERE (b.blocker_id = $2 AND b.blocked_id = p.user_id) OR (b.blocker_id = p.user_id AND b.blocked_id = $2)
+    ) AND
+    NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.muter_id = $2 AND m.muted_id = p.user_id) AND
+    ` + inVisibleCommunity("$2")
+
+// inVisibleCommunity is the condition for a post p to be shared in no
+// community, or in one that is public or has the viewer as a member. viewer
+// is the SQL the viewer's id is bound to, NULL for public communities only.
+func inVisibleCommunity(viewer string) string {
+	return `(p.community_id IS NULL OR EXISTS (
+      SELECT 1 FROM communities co
+      WHERE co.id = p.community_id AND (
+        co.visibility = 'public' OR
+        EXISTS (SELECT 1 FROM community_members cm WHERE cm.community_id = co.id AND cm.user_id = ` + viewer + `)
+      )
+    ))`
+}
+
+func scanFeed(rows *sql.Rows) ([]PostWithMetadata, error) {
+	feed := []PostWithMetadata{}
 	for rows.Next() {
 		var p PostWithMetadata
 		err := rows.Scan(
@@ -85,9 +431,11 @@ func (s *PostStore) GetUserFeed(
 			&p.UserID,
 			&p.Title,
 			&p.Content,
+			&p.ContentHTML,
 			&p.CreatedAt,
 			&p.Version,
 			pq.Array(&p.Tags),
+			&p.CommunityID,
 			&p.User.Username,
 			&p.CommentsCount,
 		)
@@ -95,37 +443,89 @@ func (s *PostStore) GetUserFeed(
 			return nil, err
 		}
 
+		if err := renderLegacyContent(&p.Post); err != nil {
+			return nil, err
+		}
+
+		p.Entities = entities.Parse(p.Content)
 		feed = append(feed, p)
 	}
 
-	return feed, nil
+	return feed, rows.Err()
 }
 
+// Create saves a post along with its hashtags, mentions and poll. The
+// hashtags of the content are added to the tags given by the author, and the
+// content is rendered to HTML.
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
-	query := `
-    INSERT INTO posts (content, title, user_id, tags)
-    VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at
-  `
+	html, err := markdown.Render(post.Content)
+	if err != nil {
+		return err
+	}
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+	post.ContentHTML = html
+	post.Entities = entities.Parse(post.Content)
+	post.AuthorTags = postHashtags(post.AuthorTags, nil)
+	post.Tags = postHashtags(post.AuthorTags, post.Entities)
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+    INSERT INTO posts (content, title, user_id, tags, community_id, content_html, author_tags)
+    VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at, updated_at
+    `
 
-	err := s.db.QueryRowContext(ctx, query, post.Content, post.Title, post.UserID, pq.Array(post.Tags)).
-		Scan(
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			post.Content,
+			post.Title,
+			post.UserID,
+			pq.Array(post.Tags),
+			post.CommunityID,
+			post.ContentHTML,
+			pq.Array(post.AuthorTags),
+		).Scan(
 			&post.ID,
 			&post.CreatedAt,
 			&post.UpdatedAt,
 		)
+		if err != nil {
+			return err
+		}
+
+		if post.Poll != nil {
+			if err := createPoll(ctx, tx, post.ID, post.Poll); err != nil {
+				return err
+			}
+		}
+
+		return s.saveEntities(ctx, tx, post)
+	})
+}
+
+// saveEntities stores the hashtags and mentions of a post and records who was
+// mentioned for the first time in MentionedIDs.
+func (s *PostStore) saveEntities(ctx context.Context, tx *sql.Tx, post *Post) error {
+	if err := saveHashtags(ctx, tx, postEntities, post.ID, post.Tags); err != nil {
+		return err
+	}
+
+	usernames := entities.Values(post.Entities, entities.TypeMention)
+	mentioned, err := saveMentions(ctx, tx, postEntities, post.ID, post.UserID, usernames)
 	if err != nil {
 		return err
 	}
 
+	post.MentionedIDs = mentioned
 	return nil
 }
 
 func (s *PostStore) GetById(ctx context.Context, id int64) (*Post, error) {
 	query := `
-  SELECT id, user_id, title, content, created_at, updated_at, tags, version
+  SELECT id, user_id, title, content, content_html, created_at, updated_at, tags, author_tags, version, is_hidden, community_id
   FROM posts
   WHERE id = $1
   `
@@ -138,10 +538,14 @@ func (s *PostStore) GetById(ctx context.Context, id int64) (*Post, error) {
 		&post.UserID,
 		&post.Title,
 		&post.Content,
+		&post.ContentHTML,
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		pq.Array(&post.Tags),
+		pq.Array(&post.AuthorTags),
 		&post.Version,
+		&post.IsHidden,
+		&post.CommunityID,
 	)
 	if err != nil {
 		switch {
@@ -151,56 +555,159 @@ func (s *PostStore) GetById(ctx context.Context, id int64) (*Post, error) {
 			return nil, err
 		}
 	}
+
+	if err := renderLegacyContent(&post); err != nil {
+		return nil, err
+	}
+
+	post.Entities = entities.Parse(post.Content)
 	return &post, nil
 }
 
+// Delete removes a post. Deleting someone else's post is recorded in the
+// audit log.
 func (s *PostStore) Delete(ctx context.Context, postID int64) error {
-	query := `DELETE FROM posts WHERE id = $1`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		before, err := s.getForUpdate(ctx, tx, postID)
+		if err != nil {
+			return err
+		}
+
+		query := `DELETE FROM posts WHERE id = $1`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, query, postID); err != nil {
+			return err
+		}
+
+		if isPrivileged(ctx, before.UserID) {
+			return recordAudit(ctx, tx, s.audit, "post.delete", "post", postID, before, nil)
+		}
+
+		return nil
+	})
+}
 
-	res, err := s.db.ExecContext(ctx, query, postID)
+// Update saves a post using optimistic locking on its version, and brings its
+// HTML, hashtags and mentions in line with the new content. Updating someone
+// else's post is recorded in the audit log.
+func (s *PostStore) Update(ctx context.Context, post *Post) error {
+	html, err := markdown.Render(post.Content)
 	if err != nil {
 		return err
 	}
 
-	rows, err := res.RowsAffected()
-	if err != nil {
-		return err
+	post.ContentHTML = html
+	post.Entities = entities.Parse(post.Content)
+	post.AuthorTags = postHashtags(post.AuthorTags, nil)
+	post.Tags = postHashtags(post.AuthorTags, post.Entities)
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		before, err := s.getForUpdate(ctx, tx, post.ID)
+		if err != nil {
+			return err
+		}
+
+		query := `
+    UPDATE posts
+    SET title=$1, content=$2, tags=$3, content_html=$6, is_hidden=$7, author_tags=$8, version = version + 1
+    WHERE id=$4 AND version=$5
+    RETURNING version
+    `
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+		err = tx.QueryRowContext(
+			ctx,
+			query,
+			post.Title,
+			post.Content,
+			pq.Array(post.Tags),
+			post.ID,
+			post.Version,
+			post.ContentHTML,
+			post.IsHidden,
+			pq.Array(post.AuthorTags),
+		).Scan(&post.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if err := s.saveEntities(ctx, tx, post); err != nil {
+			return err
+		}
+
+		if isPrivileged(ctx, before.UserID) {
+			after := *before
+			after.Title = post.Title
+			after.Content = post.Content
+			after.ContentHTML = post.ContentHTML
+			after.Tags = post.Tags
+			after.AuthorTags = post.AuthorTags
+			after.IsHidden = post.IsHidden
+			after.Version = post.Version
+
+			return recordAudit(ctx, tx, s.audit, "post.update", "post", post.ID, before, after)
+		}
+
+		return nil
+	})
+}
+
+// renderLegacyContent renders the content of posts saved before it was
+// rendered on save.
+func renderLegacyContent(post *Post) error {
+	if post.ContentHTML != "" || post.Content == "" {
+		return nil
 	}
 
-	if rows == 0 {
-		return ErrNotFound
+	html, err := markdown.Render(post.Content)
+	if err != nil {
+		return err
 	}
 
+	post.ContentHTML = html
 	return nil
 }
 
-func (s *PostStore) Update(ctx context.Context, post *Post) error {
+// getForUpdate reads and locks the current state of a post within tx.
+func (s *PostStore) getForUpdate(ctx context.Context, tx *sql.Tx, id int64) (*Post, error) {
 	query := `
-  UPDATE posts
-  SET title=$1, content=$2, tags=$3, version = version + 1
-  WHERE id=$4 AND version=$4
-  RETURNING version
+  SELECT id, user_id, title, content, content_html, created_at, updated_at, tags, author_tags, version, is_hidden, community_id
+  FROM posts
+  WHERE id = $1
+  FOR UPDATE
   `
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Title,
-		post.Content,
-		pq.Array(post.Tags),
-		post.ID,
-		post.Version,
-	).Scan(&post.Version)
+
+	var post Post
+	err := tx.QueryRowContext(ctx, query, id).Scan(
+		&post.ID,
+		&post.UserID,
+		&post.Title,
+		&post.Content,
+		&post.ContentHTML,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+		pq.Array(&post.Tags),
+		pq.Array(&post.AuthorTags),
+		&post.Version,
+		&post.IsHidden,
+		&post.CommunityID,
+	)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
+			return nil, ErrNotFound
 		default:
-			return err
+			return nil, err
 		}
 	}
-	return nil
+	return &post, nil
 }
@@ -0,0 +1,363 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/audit"
+)
+
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+	ReportTargetUser    = "user"
+
+	ReportStatusOpen      = "open"
+	ReportStatusActioned  = "actioned"
+	ReportStatusDismissed = "dismissed"
+
+	ModerationActionHide    = "hide"
+	ModerationActionWarn    = "warn"
+	ModerationActionSuspend = "suspend"
+	ModerationActionDismiss = "dismiss"
+)
+
+var (
+	ErrReportResolved = errors.New("report has already been resolved")
+	ErrOutranked      = errors.New("moderators can only act on users below their role")
+)
+
+type Report struct {
+	ID             int64              `json:"id"`
+	ReporterID     int64              `json:"reporter_id"`
+	TargetType     string             `json:"target_type"`
+	TargetID       int64              `json:"target_id"`
+	ReportedUserID int64              `json:"reported_user_id"`
+	Reason         string             `json:"reason"`
+	Status         string             `json:"status"`
+	ResolvedBy     *int64             `json:"resolved_by"`
+	ResolvedAt     *string            `json:"resolved_at"`
+	CreatedAt      string             `json:"created_at"`
+	Actions        []ModerationAction `json:"actions,omitempty"`
+}
+
+// ModerationAction is an entry in the moderation audit trail. SuspendUntil
+// is only set for suspensions.
+type ModerationAction struct {
+	ID           int64      `json:"id"`
+	ReportID     int64      `json:"report_id"`
+	ModeratorID  int64      `json:"moderator_id"`
+	Action       string     `json:"action"`
+	TargetUserID int64      `json:"target_user_id"`
+	Note         string     `json:"note"`
+	SuspendUntil *time.Time `json:"suspend_until,omitempty"`
+	CreatedAt    string     `json:"created_at"`
+}
+
+type ReportQuery struct {
+	Limit          int    `json:"limit"            validate:"gte=1,lte=50"`
+	Offset         int    `json:"offset"           validate:"gte=0"`
+	Status         string `json:"status"           validate:"omitempty,oneof=open actioned dismissed"`
+	TargetType     string `json:"target_type"      validate:"omitempty,oneof=post comment user"`
+	ReportedUserID int64  `json:"reported_user_id" validate:"gte=0"`
+}
+
+func (rq ReportQuery) Parse(r *http.Request) (ReportQuery, error) {
+	qs := r.URL.Query()
+
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return rq, err
+		}
+		rq.Limit = l
+	}
+
+	if offset := qs.Get("offset"); offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return rq, err
+		}
+		rq.Offset = o
+	}
+
+	if status := qs.Get("status"); status != "" {
+		rq.Status = status
+	}
+
+	if targetType := qs.Get("target_type"); targetType != "" {
+		rq.TargetType = targetType
+	}
+
+	if userID := qs.Get("reported_user_id"); userID != "" {
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			return rq, err
+		}
+		rq.ReportedUserID = id
+	}
+
+	return rq, nil
+}
+
+type ReportStore struct {
+	db    *sql.DB
+	audit audit.Recorder
+}
+
+func (s *ReportStore) Create(ctx context.Context, report *Report) error {
+	query := `
+  INSERT INTO reports (reporter_id, target_type, target_id, reported_user_id, reason)
+  VALUES ($1, $2, $3, $4, $5)
+  RETURNING id, status, created_at
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		report.ReporterID,
+		report.TargetType,
+		report.TargetID,
+		report.ReportedUserID,
+		report.Reason,
+	).Scan(&report.ID, &report.Status, &report.CreatedAt)
+}
+
+func (s *ReportStore) GetById(ctx context.Context, id int64) (*Report, error) {
+	query := `
+  SELECT id, reporter_id, target_type, target_id, reported_user_id, reason, status, resolved_by, resolved_at, created_at
+  FROM reports
+  WHERE id = $1
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var report Report
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&report.ID,
+		&report.ReporterID,
+		&report.TargetType,
+		&report.TargetID,
+		&report.ReportedUserID,
+		&report.Reason,
+		&report.Status,
+		&report.ResolvedBy,
+		&report.ResolvedAt,
+		&report.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &report, nil
+}
+
+// List returns the moderation queue, oldest first so reports are handled in
+// the order they came in.
+func (s *ReportStore) List(ctx context.Context, rq ReportQuery) ([]Report, error) {
+	query := `
+  SELECT id, reporter_id, target_type, target_id, reported_user_id, reason, status, resolved_by, resolved_at, created_at
+  FROM reports
+  WHERE
+    ($1 = '' OR status = $1) AND
+    ($2 = '' OR target_type = $2) AND
+    ($3 = 0 OR reported_user_id = $3)
+  ORDER BY created_at ASC
+  LIMIT $4 OFFSET $5
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, rq.Status, rq.TargetType, rq.ReportedUserID, rq.Limit, rq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []Report{}
+	for rows.Next() {
+		var report Report
+		err := rows.Scan(
+			&report.ID,
+			&report.ReporterID,
+			&report.TargetType,
+			&report.TargetID,
+			&report.ReportedUserID,
+			&report.Reason,
+			&report.Status,
+			&report.ResolvedBy,
+			&report.ResolvedAt,
+			&report.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+func (s *ReportStore) GetActions(ctx context.Context, reportID int64) ([]ModerationAction, error) {
+	query := `
+  SELECT id, report_id, COALESCE(moderator_id, 0), action, target_user_id, note, suspend_until, created_at
+  FROM moderation_actions
+  WHERE report_id = $1
+  ORDER BY created_at ASC
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []ModerationAction{}
+	for rows.Next() {
+		var a ModerationAction
+		err := rows.Scan(&a.ID, &a.ReportID, &a.ModeratorID, &a.Action, &a.TargetUserID, &a.Note, &a.SuspendUntil, &a.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+
+	return actions, rows.Err()
+}
+
+// Resolve applies a moderator action to an open report in one transaction:
+// the effect on the reported content or user, the new report status and the
+// audit trail entry. It returns ErrReportResolved when the report was already
+// closed by someone else, and ErrOutranked when the reported user is the
+// moderator or does not rank below them.
+func (s *ReportStore) Resolve(ctx context.Context, report *Report, action *ModerationAction) error {
+	status := ReportStatusActioned
+	if action.Action == ModerationActionDismiss {
+		status = ReportStatusDismissed
+	}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+    SELECT target.id <> moderator.id AND tr.level < mr.level
+    FROM users target
+    JOIN roles tr ON tr.id = target.role_id
+    CROSS JOIN users moderator
+    JOIN roles mr ON mr.id = moderator.role_id
+    WHERE target.id = $1 AND moderator.id = $2
+    `
+		var outranks bool
+		err := tx.QueryRowContext(ctx, query, action.TargetUserID, action.ModeratorID).Scan(&outranks)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+		if !outranks {
+			return ErrOutranked
+		}
+
+		query = `
+    UPDATE reports SET status = $1, resolved_by = $2, resolved_at = NOW()
+    WHERE id = $3 AND status = 'open'
+    RETURNING resolved_at
+    `
+		err = tx.QueryRowContext(ctx, query, status, action.ModeratorID, report.ID).Scan(&report.ResolvedAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrReportResolved
+			default:
+				return err
+			}
+		}
+
+		switch action.Action {
+		case ModerationActionHide:
+			if err := hideContent(ctx, tx, report.TargetType, report.TargetID); err != nil {
+				return err
+			}
+		case ModerationActionSuspend:
+			suspension := &Suspension{
+				UserID:   action.TargetUserID,
+				Reason:   action.Note,
+				IssuedBy: &action.ModeratorID,
+				EndsAt:   *action.SuspendUntil,
+			}
+			if err := createSuspension(ctx, tx, suspension); err != nil {
+				return err
+			}
+		}
+
+		query = `
+    INSERT INTO moderation_actions (report_id, moderator_id, action, target_user_id, note, suspend_until)
+    VALUES ($1, $2, $3, $4, $5, $6)
+    RETURNING id, created_at
+    `
+		err = tx.QueryRowContext(
+			ctx,
+			query,
+			report.ID,
+			action.ModeratorID,
+			action.Action,
+			action.TargetUserID,
+			action.Note,
+			action.SuspendUntil,
+		).Scan(&action.ID, &action.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		report.Status = status
+		report.ResolvedBy = &action.ModeratorID
+		action.ReportID = report.ID
+
+		return recordAudit(
+			ctx,
+			tx,
+			s.audit,
+			"moderation."+action.Action,
+			report.TargetType,
+			report.TargetID,
+			map[string]any{"report_id": report.ID, "status": ReportStatusOpen},
+			map[string]any{"report_id": report.ID, "status": status, "action": action},
+		)
+	})
+}
+
+func hideContent(ctx context.Context, tx *sql.Tx, targetType string, targetID int64) error {
+	var query string
+	switch targetType {
+	case ReportTargetPost:
+		query = `UPDATE posts SET is_hidden = true WHERE id = $1`
+	case ReportTargetComment:
+		query = `UPDATE comments SET is_hidden = true WHERE id = $1`
+	default:
+		return errors.New("only posts and comments can be hidden")
+	}
+
+	_, err := tx.ExecContext(ctx, query, targetID)
+	return err
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/audit"
 )
 
 var (
@@ -20,6 +22,12 @@ type Storage struct {
 		Delete(context.Context, int64) error
 		Update(context.Context, *Post) error
 		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetByTag(context.Context, string, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetByIds(context.Context, []int64, int64) ([]PostWithMetadata, error)
+		GetByCommunity(context.Context, int64, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetByUser(context.Context, int64, int64, UserPostsQuery) (*PostPage, error)
+		Pin(context.Context, int64) error
+		Unpin(context.Context, int64) error
 	}
 	Users interface {
 		GetById(context.Context, int64) (*User, error)
@@ -28,34 +36,125 @@ type Storage struct {
 		CreateAndInvite(context.Context, *User, string, time.Duration) error
 		Activate(context.Context, string) error
 		Delete(context.Context, int64) error
+		SetPrivacy(context.Context, int64, bool) error
+		SetRole(context.Context, int64, string) error
 	}
 	Comments interface {
 		Create(context.Context, *Comment) error
-		GetByPostId(context.Context, int64) ([]Comment, error)
+		GetById(context.Context, int64) (*Comment, error)
+		GetByPostId(context.Context, int64, int64) ([]Comment, error)
+		Delete(context.Context, int64) error
 	}
 	Followers interface {
 		Follow(context.Context, int64, int64) error
 		Unfollow(context.Context, int64, int64) error
+		IsFollowing(context.Context, int64, int64) (bool, error)
+		GetFollowerIDs(context.Context, int64) ([]int64, error)
+	}
+	FollowRequests interface {
+		Create(context.Context, int64, int64) error
+		GetIncoming(context.Context, int64) ([]FollowRequest, error)
+		Approve(context.Context, int64, int64) error
+		Delete(context.Context, int64, int64) error
+	}
+	Blocks interface {
+		Block(context.Context, int64, int64) error
+		Unblock(context.Context, int64, int64) error
+		IsBlocked(context.Context, int64, int64) (bool, error)
+		GetBlocked(context.Context, int64) ([]User, error)
+	}
+	Mutes interface {
+		Mute(context.Context, int64, int64) error
+		Unmute(context.Context, int64, int64) error
+		GetMuted(context.Context, int64) ([]User, error)
+	}
+	Reports interface {
+		Create(context.Context, *Report) error
+		GetById(context.Context, int64) (*Report, error)
+		List(context.Context, ReportQuery) ([]Report, error)
+		GetActions(context.Context, int64) ([]ModerationAction, error)
+		Resolve(context.Context, *Report, *ModerationAction) error
+	}
+	Suspensions interface {
+		Create(context.Context, *Suspension) error
+		GetByUser(context.Context, int64) ([]Suspension, error)
+	}
+	LoginThrottles interface {
+		Get(context.Context, string, string) (*LoginThrottle, error)
+		RecordFailure(context.Context, string, string, func(int) time.Duration) (*LoginThrottle, error)
+		Reset(context.Context, string, string) error
+		Unlock(context.Context, *User) error
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
 	}
+	Conversations interface {
+		GetOrCreateDirect(context.Context, int64, int64) (*Conversation, bool, error)
+		CreateGroup(context.Context, *Conversation, int64, []int64) error
+		GetForMember(context.Context, int64, int64) (*Conversation, error)
+		ListForUser(context.Context, int64, CursorQuery) (*ConversationPage, error)
+		AddMessage(context.Context, *Message) error
+		GetMessages(context.Context, int64, int64, CursorQuery) (*MessagePage, error)
+		MarkRead(context.Context, int64, int64, int64) (int64, error)
+	}
+	Polls interface {
+		GetForPosts(context.Context, []int64, int64) (map[int64]*Poll, error)
+		Vote(context.Context, int64, int64, []int64) error
+	}
+	Communities interface {
+		Create(context.Context, *Community) error
+		GetById(context.Context, int64, int64) (*Community, error)
+		GetRole(context.Context, int64, int64) (string, error)
+		Join(context.Context, *Community, int64) error
+		Leave(context.Context, int64, int64) error
+		Invite(context.Context, int64, int64, int64) error
+		GetInvitations(context.Context, int64) ([]Community, error)
+		GetForUser(context.Context, int64, int64) ([]Community, error)
+		GetMembers(context.Context, int64, CursorQuery) (*CommunityMemberPage, error)
+		SetRole(context.Context, int64, int64, string) error
+		RemoveMember(context.Context, int64, int64) error
+	}
+	Trending interface {
+		HashtagScores(context.Context, time.Time, time.Duration, int) ([]HashtagScore, error)
+		PostScores(context.Context, time.Time, time.Duration, int) ([]PostScore, error)
+	}
+	AuditLog interface {
+		List(context.Context, audit.Query) ([]audit.Entry, error)
+	}
+	FeatureFlags interface {
+		GetAll(context.Context) ([]FeatureFlag, error)
+		Set(context.Context, *FeatureFlag) error
+	}
 }
 
 func NewStorage(db *sql.DB) Storage {
+	recorder := audit.NewPostgresRecorder(db)
+
 	return Storage{
-		Posts:     &PostStore{db},
-		Users:     &UserStore{db},
-		Comments:  &CommentStore{db},
-		Followers: &FollowerStore{db},
-		Roles:     &RoleStore{db},
+		Posts:          &PostStore{db, recorder},
+		Users:          &UserStore{db, recorder},
+		Comments:       &CommentStore{db, recorder},
+		Followers:      &FollowerStore{db},
+		FollowRequests: &FollowRequestStore{db},
+		Blocks:         &BlockStore{db},
+		Mutes:          &MuteStore{db},
+		Reports:        &ReportStore{db, recorder},
+		Suspensions:    &SuspensionStore{db, recorder},
+		LoginThrottles: &LoginThrottleStore{db, recorder},
+		Roles:          &RoleStore{db},
+		Conversations:  &ConversationStore{db},
+		Communities:    &CommunityStore{db, recorder},
+		Polls:          &PollStore{db},
+		Trending:       &TrendingStore{db},
+		AuditLog:       recorder,
+		FeatureFlags:   &FeatureFlagStore{db, recorder},
 	}
 }
 
 func withTx(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil
+		return err
 	}
 
 	if err := fn(tx); err != nil {
@@ -65,3 +164,32 @@ func withTx(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
 
 	return tx.Commit()
 }
+
+// isPrivileged reports whether the change of a resource owned by ownerID is
+// made by someone else than its owner, i.e. through a role.
+func isPrivileged(ctx context.Context, ownerID int64) bool {
+	actor, ok := audit.ActorFromContext(ctx)
+	return ok && actor.UserID != ownerID
+}
+
+// recordAudit appends an entry about a privileged change to the audit log as
+// part of tx.
+func recordAudit(
+	ctx context.Context,
+	tx *sql.Tx,
+	recorder audit.Recorder,
+	action, targetType string,
+	targetID int64,
+	before, after any,
+) error {
+	if recorder == nil {
+		return nil
+	}
+
+	entry, err := audit.NewEntry(ctx, action, targetType, targetID, before, after)
+	if err != nil {
+		return err
+	}
+
+	return recorder.Record(ctx, tx, entry)
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/audit"
+)
+
+// Suspension keeps a user from signing in or using their token between
+// StartsAt and EndsAt. Suspensions lift on their own once EndsAt has passed.
+type Suspension struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Reason    string    `json:"reason"`
+	IssuedBy  *int64    `json:"issued_by"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Active    bool      `json:"active"`
+	CreatedAt string    `json:"created_at"`
+}
+
+type SuspensionStore struct {
+	db    *sql.DB
+	audit audit.Recorder
+}
+
+func (s *SuspensionStore) Create(ctx context.Context, suspension *Suspension) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := createSuspension(ctx, tx, suspension); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, s.audit, "user.suspend", "user", suspension.UserID, nil, suspension)
+	})
+}
+
+// getSuspension returns the suspension of a user which is in effect, the one
+// ending last when several overlap, or else the next one to start. It returns
+// nil when there is none.
+func getSuspension(ctx context.Context, db *sql.DB, userID int64) (*Suspension, error) {
+	query := `
+  SELECT id, user_id, reason, issued_by, starts_at, ends_at,
+    (starts_at <= NOW()) AS active,
+    created_at
+  FROM user_suspensions
+  WHERE user_id = $1 AND ends_at > NOW()
+  ORDER BY active DESC, CASE WHEN starts_at <= NOW() THEN ends_at END DESC, starts_at ASC
+  LIMIT 1
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var suspension Suspension
+	err := db.QueryRowContext(ctx, query, userID).Scan(
+		&suspension.ID,
+		&suspension.UserID,
+		&suspension.Reason,
+		&suspension.IssuedBy,
+		&suspension.StartsAt,
+		&suspension.EndsAt,
+		&suspension.Active,
+		&suspension.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &suspension, nil
+}
+
+// InEffect reports whether the suspension applies at t.
+func (s *Suspension) InEffect(t time.Time) bool {
+	return !t.Before(s.StartsAt) && t.Before(s.EndsAt)
+}
+
+func (s *SuspensionStore) GetByUser(ctx context.Context, userID int64) ([]Suspension, error) {
+	query := `
+  SELECT id, user_id, reason, issued_by, starts_at, ends_at,
+    (starts_at <= NOW() AND ends_at > NOW()) AS active,
+    created_at
+  FROM user_suspensions
+  WHERE user_id = $1
+  ORDER BY starts_at DESC
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suspensions := []Suspension{}
+	for rows.Next() {
+		var suspension Suspension
+		err := rows.Scan(
+			&suspension.ID,
+			&suspension.UserID,
+			&suspension.Reason,
+			&suspension.IssuedBy,
+			&suspension.StartsAt,
+			&suspension.EndsAt,
+			&suspension.Active,
+			&suspension.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		suspensions = append(suspensions, suspension)
+	}
+
+	return suspensions, rows.Err()
+}
+
+func createSuspension(ctx context.Context, tx *sql.Tx, suspension *Suspension) error {
+	query := `
+  INSERT INTO user_suspensions (user_id, reason, issued_by, starts_at, ends_at)
+  VALUES ($1, $2, $3, $4, $5)
+  RETURNING id, (starts_at <= NOW() AND ends_at > NOW()), created_at
+  `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if suspension.StartsAt.IsZero() {
+		suspension.StartsAt = time.Now()
+	}
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		suspension.UserID,
+		suspension.Reason,
+		suspension.IssuedBy,
+		suspension.StartsAt,
+		suspension.EndsAt,
+	).Scan(&suspension.ID, &suspension.Active, &suspension.CreatedAt)
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type HashtagScore struct {
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
+
+type PostScore struct {
+	PostID int64   `json:"post_id"`
+	Score  float64 `json:"score"`
+}
+
+// TrendingStore scores recent activity on public content, community posts
+// counting only in public communities. Every use counts
+// for less the older it is, halving every halfLife, so what grows quickly now
+// ranks above what was popular earlier in the window. Posts are also weighted
+// by how their activity grew since the previous window.
+type TrendingStore struct {
+	db *sql.DB
+}
+
+// HashtagScores scores hashtags on their use in posts and comments since the
+// given time.
+func (s *TrendingStore) HashtagScores(
+	ctx context.Context,
+	since time.Time,
+	halfLife time.Duration,
+	limit int,
+) ([]HashtagScore, error) {
+	query := `
+  SELECT tag, SUM(weight) AS score FROM (
+    SELECT lower(unnest(p.tags)) AS tag, POWER(0.5, EXTRACT(EPOCH FROM NOW() - p.created_at) / $2) AS weight
+    FROM posts p
+    JOIN users u ON u.id = p.user_id
+    WHERE p.created_at >= $1 AND NOT p.is_hidden AND NOT u.is_private AND
+      ` + inVisibleCommunity("NULL") + `
+    UNION ALL
+    SELECT h.name, POWER(0.5, EXTRACT(EPOCH FROM NOW() - ch.created_at) / $2)
+    FROM comment_hashtags ch
+    JOIN hashtags h ON h.id = ch.hashtag_id
+    JOIN comments c ON c.id = ch.comment_id
+    JOIN posts p ON p.id = c.post_id
+    JOIN users u ON u.id = p.user_id
+    WHERE ch.created_at >= $1 AND NOT c.is_hidden AND NOT p.is_hidden AND NOT u.is_private AND
+      ` + inVisibleCommunity("NULL") + `
+  ) AS uses
+  GROUP BY tag
+  ORDER BY score DESC
+  LIMIT $3
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, since, halfLife.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scores := []HashtagScore{}
+	for rows.Next() {
+		var score HashtagScore
+		if err := rows.Scan(&score.Name, &score.Score); err != nil {
+			return nil, err
+		}
+		scores = append(scores, score)
+	}
+
+	return scores, rows.Err()
+}
+
+// PostScores scores posts on the comments they received since the given time,
+// weighted by their growth: the ratio of those comments to the ones received
+// over the same length of time before, so that a post picking up pace ranks
+// above one slowing down. Authors commenting on their own posts don't count.
+// Posts have no reactions yet; once they do, they belong in the same sums.
+func (s *TrendingStore) PostScores(
+	ctx context.Context,
+	since time.Time,
+	halfLife time.Duration,
+	limit int,
+) ([]PostScore, error) {
+	query := `
+  SELECT p.id,
+    SUM(POWER(0.5, EXTRACT(EPOCH FROM NOW() - c.created_at) / $2)) FILTER (WHERE c.created_at >= $1) *
+    (COUNT(*) FILTER (WHERE c.created_at >= $1) + 1) / (COUNT(*) FILTER (WHERE c.created_at < $1) + 1)::float AS score
+  FROM comments c
+  JOIN posts p ON p.id = c.post_id
+  JOIN users u ON u.id = p.user_id
+  WHERE
+    c.created_at >= $4 AND c.user_id <> p.user_id AND
+    NOT c.is_hidden AND NOT p.is_hidden AND NOT u.is_private AND
+    ` + inVisibleCommunity("NULL") + `
+  GROUP BY p.id
+  HAVING COUNT(*) FILTER (WHERE c.created_at >= $1) > 0
+  ORDER BY score DESC
+  LIMIT $3
+  `
+	// the previous window, as long as the current one
+	previous := since.Add(-time.Since(since))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, since, halfLife.Seconds(), limit, previous)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scores := []PostScore{}
+	for rows.Next() {
+		var score PostScore
+		if err := rows.Scan(&score.PostID, &score.Score); err != nil {
+			return nil, err
+		}
+		scores = append(scores, score)
+	}
+
+	return scores, rows.Err()
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/shanisharrma/gopher-social/internal/audit"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -21,8 +22,12 @@ type User struct {
 	Password  password `json:"-"`
 	CreatedAt string   `json:"created_at"`
 	IsActive  bool     `json:"is_active"`
+	IsPrivate bool     `json:"is_private"`
 	RoleID    int64    `json:"role_id"`
 	Role      Role     `json:"role"`
+	// Suspension is the suspension in effect, or the next one, as loaded by
+	// GetById. It is private to the API.
+	Suspension *Suspension `json:"-"`
 }
 
 type password struct {
@@ -42,8 +47,22 @@ func (p *password) Set(text string) error {
 	return nil
 }
 
+// Matches reports whether text is the password.
+func (p *password) Matches(text string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(p.hash, []byte(text))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
 type UserStore struct {
-	db *sql.DB
+	db    *sql.DB
+	audit audit.Recorder
 }
 
 func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
@@ -77,7 +96,7 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 
 func (s *UserStore) GetById(ctx context.Context, userId int64) (*User, error) {
 	query := `
-  SELECT users.id, username, email, password, created_at, is_active,roles.*
+  SELECT users.id, username, email, password, created_at, is_active, is_private, roles.*
   FROM users
   JOIN roles ON (users.role_id = roles.id)
   WHERE users.id = $1 AND is_active = true;
@@ -88,7 +107,7 @@ func (s *UserStore) GetById(ctx context.Context, userId int64) (*User, error) {
 
 	user := &User{}
 	err := s.db.QueryRowContext(ctx, query, userId).
-		Scan(&user.ID, &user.Username, &user.Email, &user.Password.hash, &user.CreatedAt, &user.IsActive, &user.Role.ID, &user.Role.Name, &user.Role.Level, &user.Role.Description)
+		Scan(&user.ID, &user.Username, &user.Email, &user.Password.hash, &user.CreatedAt, &user.IsActive, &user.IsPrivate, &user.Role.ID, &user.Role.Name, &user.Role.Level, &user.Role.Description)
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -98,6 +117,13 @@ func (s *UserStore) GetById(ctx context.Context, userId int64) (*User, error) {
 		}
 	}
 
+	// loaded here so that it is cached with the user, and checking it doesn't
+	// cost a query on every authenticated request
+	user.Suspension, err = getSuspension(ctx, s.db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return user, nil
 }
 
@@ -178,6 +204,83 @@ func (s *UserStore) Delete(ctx context.Context, userID int64) error {
 	})
 }
 
+func (s *UserStore) SetPrivacy(ctx context.Context, userID int64, isPrivate bool) error {
+	query := `UPDATE users SET is_private = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, isPrivate, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetRole gives a user another role. Role changes are always recorded in the
+// audit log.
+func (s *UserStore) SetRole(ctx context.Context, userID int64, roleName string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var before string
+		query := `
+    SELECT r.name FROM users u JOIN roles r ON r.id = u.role_id
+    WHERE u.id = $1
+    FOR UPDATE OF u
+    `
+		if err := tx.QueryRowContext(ctx, query, userID).Scan(&before); err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		query = `
+    UPDATE users SET role_id = roles.id
+    FROM roles
+    WHERE roles.name = $1 AND users.id = $2
+    `
+		res, err := tx.ExecContext(ctx, query, roleName, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return recordAudit(
+			ctx,
+			tx,
+			s.audit,
+			"user.role_change",
+			"user",
+			userID,
+			map[string]string{"role": before},
+			map[string]string{"role": roleName},
+		)
+	})
+}
+
 func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, hashToken string) (*User, error) {
 	query := `
 	SELECT u.id, u.username, u.email, u.created_at, u.is_active
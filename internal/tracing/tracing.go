@@ -0,0 +1,107 @@
+// Package tracing sets up OpenTelemetry tracing: spans are exported over
+// OTLP/HTTP and trace context is propagated with the W3C headers.
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const instrumentationName = "github.com/shanisharrma/gopher-social"
+
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Endpoint is the host:port of the OTLP/HTTP collector. When empty, the
+	// OTEL_EXPORTER_OTLP_* variables apply.
+	Endpoint string `yaml:"endpoint"`
+	Insecure bool   `yaml:"insecure"`
+	// SampleRatio is the share of traces started here that are recorded.
+	// Traces started upstream follow the decision of their caller.
+	SampleRatio float64 `yaml:"sample_ratio"`
+	ServiceName string  `yaml:"service_name"`
+}
+
+// Setup installs the propagator and, when tracing is enabled, a tracer
+// provider exporting to the collector of cfg. The returned function flushes
+// pending spans and must be called before exiting.
+func Setup(ctx context.Context, cfg Config, version string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := Install(sdktrace.NewBatchSpanProcessor(exporter), cfg, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider.Shutdown, nil
+}
+
+// Install makes a tracer provider handing spans to processor the global one.
+// Tests pass a processor around an in-memory exporter.
+func Install(processor sdktrace.SpanProcessor, cfg Config, version string) (*sdktrace.TracerProvider, error) {
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, errors.New("trace sample ratio must be between 0 and 1")
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider, nil
+}
+
+// Tracer is the tracer of the application's own spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Logger adds the IDs of the span of ctx, if any, to the entries of logger,
+// so that logs can be matched with traces.
+func Logger(ctx context.Context, logger *zap.SugaredLogger) *zap.SugaredLogger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return logger
+	}
+
+	return logger.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+}
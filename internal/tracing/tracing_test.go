@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMiddleware(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+
+	if _, err := Setup(context.Background(), Config{}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := Install(sdktrace.NewSimpleSpanProcessor(exporter), Config{SampleRatio: 1, ServiceName: "test"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core).Sugar()
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/posts/{postID}", func(w http.ResponseWriter, r *http.Request) {
+		Logger(r.Context(), logger).Info("fetching post")
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	req := httptest.NewRequest(http.MethodGet, "/posts/42", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span. got %d", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name != "GET /posts/{postID}" {
+		t.Errorf("expected the span to be named after the route. got %q", span.Name)
+	}
+
+	if got := span.SpanContext.TraceID().String(); got != traceID {
+		t.Errorf("expected the span to continue trace %s. got %s", traceID, got)
+	}
+
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].ContextMap()["trace_id"] != traceID {
+		t.Errorf("expected the log entry to carry trace %s. got %v", traceID, entries)
+	}
+}
@@ -0,0 +1,53 @@
+package trending
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+// MemoryResults keeps the rankings of a single instance.
+type MemoryResults struct {
+	mu       sync.RWMutex
+	hashtags map[string][]store.HashtagScore
+	posts    map[string][]store.PostScore
+}
+
+func NewMemoryResults() *MemoryResults {
+	return &MemoryResults{
+		hashtags: make(map[string][]store.HashtagScore),
+		posts:    make(map[string][]store.PostScore),
+	}
+}
+
+func (r *MemoryResults) Save(ctx context.Context, window string, hashtags []store.HashtagScore, posts []store.PostScore) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hashtags[window] = hashtags
+	r.posts[window] = posts
+
+	return nil
+}
+
+func (r *MemoryResults) Hashtags(ctx context.Context, window string, limit int) ([]store.HashtagScore, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hashtags := r.hashtags[window]
+	return append([]store.HashtagScore{}, hashtags[:min(limit, len(hashtags))]...), nil
+}
+
+func (r *MemoryResults) Posts(ctx context.Context, window string, limit int) ([]store.PostScore, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	posts := r.posts[window]
+	return append([]store.PostScore{}, posts[:min(limit, len(posts))]...), nil
+}
+
+func (r *MemoryResults) Lock(ctx context.Context, ttl time.Duration) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,99 @@
+package trending
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shanisharrma/gopher-social/internal/store"
+)
+
+const (
+	keyPrefix = "trending:"
+	lockKey   = keyPrefix + "lock"
+)
+
+// RedisResults shares the rankings between instances, one sorted set per
+// window and kind.
+type RedisResults struct {
+	rdb *redis.Client
+}
+
+func NewRedisResults(rdb *redis.Client) *RedisResults {
+	return &RedisResults{rdb: rdb}
+}
+
+func hashtagsKey(window string) string {
+	return keyPrefix + window + ":hashtags"
+}
+
+func postsKey(window string) string {
+	return keyPrefix + window + ":posts"
+}
+
+// Save replaces the rankings of a window at once, so readers never see a
+// half written set.
+func (r *RedisResults) Save(ctx context.Context, window string, hashtags []store.HashtagScore, posts []store.PostScore) error {
+	tagMembers := make([]*redis.Z, 0, len(hashtags))
+	for _, h := range hashtags {
+		tagMembers = append(tagMembers, &redis.Z{Score: h.Score, Member: h.Name})
+	}
+
+	postMembers := make([]*redis.Z, 0, len(posts))
+	for _, p := range posts {
+		postMembers = append(postMembers, &redis.Z{Score: p.Score, Member: strconv.FormatInt(p.PostID, 10)})
+	}
+
+	pipe := r.rdb.TxPipeline()
+	replace(ctx, pipe, hashtagsKey(window), tagMembers)
+	replace(ctx, pipe, postsKey(window), postMembers)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func replace(ctx context.Context, pipe redis.Pipeliner, key string, members []*redis.Z) {
+	pipe.Del(ctx, key)
+	if len(members) > 0 {
+		pipe.ZAdd(ctx, key, members...)
+	}
+}
+
+func (r *RedisResults) Hashtags(ctx context.Context, window string, limit int) ([]store.HashtagScore, error) {
+	members, err := r.rdb.ZRevRangeWithScores(ctx, hashtagsKey(window), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	hashtags := make([]store.HashtagScore, 0, len(members))
+	for _, m := range members {
+		name, _ := m.Member.(string)
+		hashtags = append(hashtags, store.HashtagScore{Name: name, Score: m.Score})
+	}
+
+	return hashtags, nil
+}
+
+func (r *RedisResults) Posts(ctx context.Context, window string, limit int) ([]store.PostScore, error) {
+	members, err := r.rdb.ZRevRangeWithScores(ctx, postsKey(window), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]store.PostScore, 0, len(members))
+	for _, m := range members {
+		member, _ := m.Member.(string)
+		id, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		posts = append(posts, store.PostScore{PostID: id, Score: m.Score})
+	}
+
+	return posts, nil
+}
+
+func (r *RedisResults) Lock(ctx context.Context, ttl time.Duration) (bool, error) {
+	return r.rdb.SetNX(ctx, lockKey, 1, ttl).Result()
+}
@@ -0,0 +1,126 @@
+// Package trending keeps the rankings of the discover page. A background job
+// scores recent activity for each window and saves the results, which
+// requests then read without touching the database.
+package trending
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/store"
+	"go.uber.org/zap"
+)
+
+// Size is how many hashtags and posts are kept per window.
+const Size = 100
+
+// ErrUnknownWindow is returned for window names not in Windows.
+var ErrUnknownWindow = errors.New("unknown trending window")
+
+// Window is a period activity is scored over. Uses lose half their weight
+// every HalfLife, a quarter of the window, so a window favours what is
+// growing now over what was popular at its start.
+type Window struct {
+	Name     string
+	Duration time.Duration
+	HalfLife time.Duration
+}
+
+var Windows = []Window{
+	{Name: "1h", Duration: time.Hour, HalfLife: 15 * time.Minute},
+	{Name: "24h", Duration: 24 * time.Hour, HalfLife: 6 * time.Hour},
+	{Name: "7d", Duration: 7 * 24 * time.Hour, HalfLife: 42 * time.Hour},
+}
+
+func WindowByName(name string) (Window, error) {
+	for _, w := range Windows {
+		if w.Name == name {
+			return w, nil
+		}
+	}
+
+	return Window{}, ErrUnknownWindow
+}
+
+// Source computes the scores, see store.TrendingStore.
+type Source interface {
+	HashtagScores(ctx context.Context, since time.Time, halfLife time.Duration, limit int) ([]store.HashtagScore, error)
+	PostScores(ctx context.Context, since time.Time, halfLife time.Duration, limit int) ([]store.PostScore, error)
+}
+
+// Results holds the latest rankings of each window, highest score first.
+type Results interface {
+	Save(ctx context.Context, window string, hashtags []store.HashtagScore, posts []store.PostScore) error
+	Hashtags(ctx context.Context, window string, limit int) ([]store.HashtagScore, error)
+	Posts(ctx context.Context, window string, limit int) ([]store.PostScore, error)
+	// Lock reports whether this instance should refresh the rankings now,
+	// so instances sharing results don't all compute them.
+	Lock(ctx context.Context, ttl time.Duration) (bool, error)
+}
+
+// Job refreshes the rankings every interval.
+type Job struct {
+	source   Source
+	results  Results
+	interval time.Duration
+	logger   *zap.SugaredLogger
+}
+
+func NewJob(source Source, results Results, interval time.Duration, logger *zap.SugaredLogger) *Job {
+	return &Job{
+		source:   source,
+		results:  results,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run refreshes the rankings right away, then every interval until ctx is
+// done.
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := j.Refresh(ctx); err != nil {
+			j.logger.Errorw("error refreshing trending", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh recomputes every window, unless another instance already does.
+func (j *Job) Refresh(ctx context.Context) error {
+	// released by expiry, a little before the next run
+	locked, err := j.results.Lock(ctx, j.interval*9/10)
+	if err != nil || !locked {
+		return err
+	}
+
+	now := time.Now()
+	for _, w := range Windows {
+		since := now.Add(-w.Duration)
+
+		hashtags, err := j.source.HashtagScores(ctx, since, w.HalfLife, Size)
+		if err != nil {
+			return err
+		}
+
+		posts, err := j.source.PostScores(ctx, since, w.HalfLife, Size)
+		if err != nil {
+			return err
+		}
+
+		if err := j.results.Save(ctx, w.Name, hashtags, posts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package trending
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shanisharrma/gopher-social/internal/store"
+	"go.uber.org/zap"
+)
+
+type fakeSource struct {
+	windows []time.Duration
+}
+
+func (s *fakeSource) HashtagScores(ctx context.Context, since time.Time, halfLife time.Duration, limit int) ([]store.HashtagScore, error) {
+	s.windows = append(s.windows, halfLife)
+
+	return []store.HashtagScore{{Name: "go", Score: 3}, {Name: "rust", Score: 2}, {Name: "zig", Score: 1}}, nil
+}
+
+func (s *fakeSource) PostScores(ctx context.Context, since time.Time, halfLife time.Duration, limit int) ([]store.PostScore, error) {
+	return []store.PostScore{{PostID: 7, Score: 1.5}}, nil
+}
+
+type lockedResults struct {
+	*MemoryResults
+}
+
+func (r lockedResults) Lock(ctx context.Context, ttl time.Duration) (bool, error) {
+	return false, nil
+}
+
+func TestJobRefresh(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should save every window", func(t *testing.T) {
+		source := &fakeSource{}
+		results := NewMemoryResults()
+		job := NewJob(source, results, time.Minute, zap.NewNop().Sugar())
+
+		if err := job.Refresh(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(source.windows) != len(Windows) {
+			t.Fatalf("expected %d windows. got %d", len(Windows), len(source.windows))
+		}
+
+		hashtags, _ := results.Hashtags(ctx, "24h", 2)
+		if len(hashtags) != 2 || hashtags[0].Name != "go" {
+			t.Errorf("unexpected hashtags %+v", hashtags)
+		}
+
+		posts, _ := results.Posts(ctx, "7d", 10)
+		if len(posts) != 1 || posts[0].PostID != 7 {
+			t.Errorf("unexpected posts %+v", posts)
+		}
+	})
+
+	t.Run("should leave the work to the instance holding the lock", func(t *testing.T) {
+		source := &fakeSource{}
+		job := NewJob(source, lockedResults{NewMemoryResults()}, time.Minute, zap.NewNop().Sugar())
+
+		if err := job.Refresh(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(source.windows) != 0 {
+			t.Errorf("expected no refresh. got %d windows", len(source.windows))
+		}
+	})
+}